@@ -0,0 +1,140 @@
+package ssh
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// NegotiatedAlgorithms are the algorithms agreed with the SSH server for a connection.
+type NegotiatedAlgorithms struct {
+	KeyExchange string `yaml:"key_exchange" json:"key_exchange"`
+	HostKey     string `yaml:"host_key" json:"host_key"`
+	// Cipher and MAC are from the client to the server, MAC is empty for AEAD ciphers.
+	Cipher string `yaml:"cipher" json:"cipher"`
+	MAC    string `yaml:"mac,omitempty" json:"mac,omitempty"`
+}
+
+// algorithmList is a list of algorithms of the client information with the algorithms that exist for it.
+type algorithmList struct {
+	name       string
+	configured *[]string
+	supported  []string
+	insecure   []string
+}
+
+// algorithmLists returns the lists of algorithms of the client information.
+func (info *ClientInfo) algorithmLists() []algorithmList {
+	supported := ssh.SupportedAlgorithms()
+	insecure := ssh.InsecureAlgorithms()
+	return []algorithmList{
+		{name: "cipher", configured: &info.Ciphers, supported: supported.Ciphers, insecure: insecure.Ciphers},
+		{name: "key exchange", configured: &info.KeyExchanges, supported: supported.KeyExchanges, insecure: insecure.KeyExchanges},
+		{name: "MAC", configured: &info.MACs, supported: supported.MACs, insecure: insecure.MACs},
+		{name: "host key algorithm", configured: &info.HostKeyAlgorithms, supported: supported.HostKeys, insecure: insecure.HostKeys},
+	}
+}
+
+// ValidateAlgorithms returns an error when an algorithm of the client information is not implemented.
+func (info *ClientInfo) ValidateAlgorithms() error {
+	for _, list := range info.algorithmLists() {
+		for _, algorithm := range *list.configured {
+			if !list.implements(algorithm) {
+				return fmt.Errorf("unsupported %s %q: must be one of %s", list.name, strings.TrimPrefix(algorithm, "+"), strings.Join(append(slices.Clone(list.supported), list.insecure...), ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// removeUnsupportedAlgorithms removes the algorithms that are not implemented from the client information and returns them.
+func (info *ClientInfo) removeUnsupportedAlgorithms() []string {
+	var removed []string
+	for _, list := range info.algorithmLists() {
+		configured := *list.configured
+		if len(configured) == 0 {
+			continue
+		}
+		add := strings.HasPrefix(configured[0], "+")
+		var kept []string
+		for _, algorithm := range configured {
+			if list.implements(algorithm) {
+				kept = append(kept, strings.TrimPrefix(algorithm, "+"))
+			} else {
+				removed = append(removed, strings.TrimPrefix(algorithm, "+"))
+			}
+		}
+		if add && len(kept) > 0 {
+			kept[0] = "+" + kept[0]
+		}
+		*list.configured = kept
+	}
+	return removed
+}
+
+// implements returns true when the algorithm is implemented.
+func (list algorithmList) implements(algorithm string) bool {
+	algorithm = strings.TrimPrefix(algorithm, "+")
+	return slices.Contains(list.supported, algorithm) || slices.Contains(list.insecure, algorithm)
+}
+
+// resolve returns the algorithms to use, defaults when none are configured.
+//
+// Like OpenSSH a list that starts with a + is appended to the defaults (the secure algorithms when nil).
+func (list algorithmList) resolve(defaults []string) []string {
+	configured := *list.configured
+	if len(configured) == 0 {
+		return defaults
+	}
+	if !strings.HasPrefix(configured[0], "+") {
+		return configured
+	}
+	if defaults == nil {
+		defaults = list.supported
+	}
+	algorithms := slices.Clone(defaults)
+	for _, algorithm := range configured {
+		algorithm = strings.TrimPrefix(algorithm, "+")
+		if !slices.Contains(algorithms, algorithm) {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	return algorithms
+}
+
+// applyAlgorithms sets the algorithms of the client information on the configuration.
+//
+// Host key algorithms that are added with a + are added to the ones already in the configuration
+// (from the known host), so the server still presents the type of key that is known.
+func (c *Client) applyAlgorithms(cfg *ssh.ClientConfig) error {
+	err := c.info.ValidateAlgorithms()
+	if err != nil {
+		return err
+	}
+	lists := c.info.algorithmLists()
+	cfg.Ciphers = lists[0].resolve(cfg.Ciphers)
+	cfg.KeyExchanges = lists[1].resolve(cfg.KeyExchanges)
+	cfg.MACs = lists[2].resolve(cfg.MACs)
+	cfg.HostKeyAlgorithms = lists[3].resolve(cfg.HostKeyAlgorithms)
+	return nil
+}
+
+// Algorithms returns the algorithms negotiated with the SSH server, nil when not connected.
+func (c *Client) Algorithms() *NegotiatedAlgorithms {
+	if c.client == nil {
+		return nil
+	}
+	conn, ok := c.client.Conn.(ssh.AlgorithmsConnMetadata)
+	if !ok {
+		return nil
+	}
+	algorithms := conn.Algorithms()
+	return &NegotiatedAlgorithms{
+		KeyExchange: algorithms.KeyExchange,
+		HostKey:     algorithms.HostKey,
+		Cipher:      algorithms.Write.Cipher,
+		MAC:         algorithms.Write.MAC,
+	}
+}
@@ -0,0 +1,129 @@
+package ssh
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// legacyServer only allows the algorithms of old appliances.
+func legacyServer(t *testing.T) func(cfg *ssh.ServerConfig) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("failed to create host key signer: %v", err)
+	}
+	legacySigner, err := ssh.NewSignerWithAlgorithms(signer.(ssh.AlgorithmSigner), []string{ssh.KeyAlgoRSA})
+	if err != nil {
+		t.Fatalf("failed to restrict host key algorithms: %v", err)
+	}
+	return func(cfg *ssh.ServerConfig) {
+		cfg.KeyExchanges = []string{ssh.InsecureKeyExchangeDH1SHA1}
+		cfg.Ciphers = []string{ssh.InsecureCipherAES128CBC}
+		cfg.MACs = []string{ssh.HMACSHA1}
+		cfg.AddHostKey(legacySigner)
+	}
+}
+
+func TestClient_Algorithms(t *testing.T) {
+	server := newTestServer(t, echoHandler, legacyServer(t))
+
+	// the legacy algorithms are not allowed by default
+	client := NewClient(&server.info, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	err := client.Connect(context.Background())
+	if err == nil {
+		_ = client.Close()
+		t.Fatal("expected connecting with the default algorithms to fail")
+	}
+
+	info := server.info
+	info.KeyExchanges = []string{"+" + ssh.InsecureKeyExchangeDH1SHA1}
+	info.Ciphers = []string{ssh.InsecureCipherAES128CBC}
+	info.MACs = []string{ssh.HMACSHA1}
+	info.HostKeyAlgorithms = []string{ssh.KeyAlgoRSA}
+	client = NewClient(&info, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	if algorithms := client.Algorithms(); algorithms != nil {
+		t.Errorf("expected no algorithms before connecting, got %+v", algorithms)
+	}
+	err = client.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer client.Close()
+
+	expected := NegotiatedAlgorithms{
+		KeyExchange: ssh.InsecureKeyExchangeDH1SHA1,
+		HostKey:     ssh.KeyAlgoRSA,
+		Cipher:      ssh.InsecureCipherAES128CBC,
+		MAC:         ssh.HMACSHA1,
+	}
+	if algorithms := client.Algorithms(); algorithms == nil || *algorithms != expected {
+		t.Errorf("expected %+v, got %+v", expected, algorithms)
+	}
+}
+
+func TestClientInfo_ValidateAlgorithms(t *testing.T) {
+	info := ClientInfo{
+		KeyExchanges:      []string{"+" + ssh.InsecureKeyExchangeDH1SHA1},
+		HostKeyAlgorithms: []string{ssh.KeyAlgoRSA, ssh.KeyAlgoED25519},
+	}
+	err := info.ValidateAlgorithms()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	info.Ciphers = []string{"blowfish-cbc"}
+	err = info.ValidateAlgorithms()
+	if err == nil {
+		t.Error("expected an unknown cipher to fail")
+	}
+}
+
+func TestAlgorithmList_Resolve(t *testing.T) {
+	supported := ssh.SupportedAlgorithms().KeyExchanges
+
+	list := algorithmList{configured: &[]string{}, supported: supported}
+	if algorithms := list.resolve(nil); algorithms != nil {
+		t.Errorf("expected the defaults, got %v", algorithms)
+	}
+
+	list.configured = &[]string{ssh.InsecureKeyExchangeDH1SHA1}
+	if algorithms := list.resolve(nil); !slices.Equal(algorithms, []string{ssh.InsecureKeyExchangeDH1SHA1}) {
+		t.Errorf("expected only the configured algorithm, got %v", algorithms)
+	}
+
+	// added algorithms come after the defaults
+	list.configured = &[]string{"+" + ssh.InsecureKeyExchangeDH1SHA1}
+	algorithms := list.resolve(nil)
+	if !slices.Equal(algorithms, append(slices.Clone(supported), ssh.InsecureKeyExchangeDH1SHA1)) {
+		t.Errorf("expected the algorithm after the secure algorithms, got %v", algorithms)
+	}
+	algorithms = list.resolve([]string{ssh.KeyExchangeCurve25519})
+	if !slices.Equal(algorithms, []string{ssh.KeyExchangeCurve25519, ssh.InsecureKeyExchangeDH1SHA1}) {
+		t.Errorf("expected the algorithm after the defaults, got %v", algorithms)
+	}
+}
+
+func TestClientInfo_RemoveUnsupportedAlgorithms(t *testing.T) {
+	info := ClientInfo{
+		Ciphers: []string{"+blowfish-cbc", ssh.InsecureCipherAES128CBC},
+		MACs:    []string{"umac-64@openssh.com"},
+	}
+	removed := info.removeUnsupportedAlgorithms()
+	if !slices.Equal(removed, []string{"blowfish-cbc", "umac-64@openssh.com"}) {
+		t.Errorf("expected the unsupported algorithms to be removed, got %v", removed)
+	}
+	if !slices.Equal(info.Ciphers, []string{"+" + ssh.InsecureCipherAES128CBC}) {
+		t.Errorf("expected the remaining cipher to be added to the defaults, got %v", info.Ciphers)
+	}
+	if info.MACs != nil {
+		t.Errorf("expected the default MACs, got %v", info.MACs)
+	}
+}
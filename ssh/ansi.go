@@ -0,0 +1,12 @@
+package ssh
+
+import "regexp"
+
+// ansiPattern matches the ANSI escape sequences written by programs running in a terminal: CSI sequences
+// (colors, cursor movement), OSC sequences (window titles) and the other two byte escapes.
+var ansiPattern = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[()][0-9A-Za-z]|[@-Z\\-_])`)
+
+// stripANSI removes the ANSI escape sequences from the output.
+func stripANSI(output string) string {
+	return ansiPattern.ReplaceAllString(output, "")
+}
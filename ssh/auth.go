@@ -0,0 +1,220 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// authMethods returns the authentication methods based on what is configured for the client.
+//
+// Public key authentication is tried first (with the certificate, if any), then the ssh-agent and
+// finally password authentication.
+func (c *Client) authMethods() ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if c.info.KeyPath != "" {
+		signer, err := loadPrivateKey(c.info.KeyPath, c.info.KeyPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		signers := []ssh.Signer{signer}
+		certPath := c.info.CertificatePath()
+		if certPath != "" {
+			cert, err := loadCertificate(certPath)
+			if err != nil {
+				return nil, err
+			}
+			certSigner, err := ssh.NewCertSigner(cert, signer)
+			if err != nil {
+				return nil, fmt.Errorf("failed to use certificate %s: %w", certPath, err)
+			}
+			signers = []ssh.Signer{certSigner, signer}
+		}
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	if c.info.UseAgent {
+		method, err := c.agentAuth()
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, method)
+	}
+	if c.info.Pass != "" {
+		methods = append(methods, ssh.Password(c.info.Pass))
+	}
+	if c.info.KeyboardInteractive {
+		if c.challenge == nil {
+			return nil, ErrNoKeyboardInteractive
+		}
+		methods = append(methods, ssh.KeyboardInteractive(c.keyboardInteractive))
+	}
+	if len(methods) == 0 {
+		return nil, ErrNoAuthMethod
+	}
+	return methods, nil
+}
+
+// loadPrivateKey reads and parses the private key at the path.
+func loadPrivateKey(path string, passphrase string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	var signer ssh.Signer
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	return signer, nil
+}
+
+// KeyboardInteractiveChallenge answers the questions asked by the server of the host during keyboard-interactive
+// authentication.
+type KeyboardInteractiveChallenge func(host, name, instruction string, questions []string, echos []bool) ([]string, error)
+
+// keyboardInteractive answers password questions with the stored password and forwards the remaining
+// questions (e.g. one-time passwords) to the challenge callback.
+func (c *Client) keyboardInteractive(name, instruction string, questions []string, echos []bool) ([]string, error) {
+	answers := make([]string, len(questions))
+	var remaining []int
+	for i, question := range questions {
+		if c.info.Pass != "" && !echos[i] && strings.Contains(strings.ToLower(question), "password") {
+			answers[i] = c.info.Pass
+			continue
+		}
+		remaining = append(remaining, i)
+	}
+	if len(remaining) == 0 {
+		return answers, nil
+	}
+
+	remainingQuestions := make([]string, 0, len(remaining))
+	remainingEchos := make([]bool, 0, len(remaining))
+	for _, i := range remaining {
+		remainingQuestions = append(remainingQuestions, questions[i])
+		remainingEchos = append(remainingEchos, echos[i])
+	}
+	remainingAnswers, err := c.challenge(c.info.Name, name, instruction, remainingQuestions, remainingEchos)
+	if err != nil {
+		return nil, err
+	}
+	if len(remainingAnswers) != len(remaining) {
+		return nil, fmt.Errorf("expected %d keyboard-interactive answers, got %d", len(remaining), len(remainingAnswers))
+	}
+	for j, i := range remaining {
+		answers[i] = remainingAnswers[j]
+	}
+	return answers, nil
+}
+
+// CertificatePath returns the path of the user certificate for the client.
+//
+// This is either the configured certificate or the "-cert.pub" file next to the private key (when it exists).
+func (info *ClientInfo) CertificatePath() string {
+	if info.CertPath != "" {
+		return info.CertPath
+	}
+	if info.KeyPath == "" {
+		return ""
+	}
+	certPath := info.KeyPath + "-cert.pub"
+	_, err := os.Stat(certPath)
+	if err != nil {
+		return ""
+	}
+	return certPath
+}
+
+// CertificateExpiry returns when the user certificate for the client expires.
+//
+// Returns false when no certificate is used or the certificate never expires.
+func (info *ClientInfo) CertificateExpiry() (time.Time, bool, error) {
+	certPath := info.CertificatePath()
+	if certPath == "" {
+		return time.Time{}, false, nil
+	}
+	cert, err := loadCertificate(certPath)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if cert.ValidBefore == ssh.CertTimeInfinity {
+		return time.Time{}, false, nil
+	}
+	return time.Unix(int64(cert.ValidBefore), 0).UTC(), true, nil
+}
+
+// loadCertificate reads and parses the user certificate at the path.
+func loadCertificate(path string) (*ssh.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.New("failed to parse certificate: not an SSH certificate")
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, errors.New("failed to parse certificate: not a user certificate")
+	}
+	return cert, nil
+}
+
+// agentAuth connects to the ssh-agent and returns an authentication method that uses its keys.
+func (c *Client) agentAuth() (ssh.AuthMethod, error) {
+	conn, err := dialAgent()
+	if err != nil {
+		return nil, err
+	}
+	c.agentConn = conn
+	return ssh.PublicKeysCallback(agent.NewClient(conn).Signers), nil
+}
+
+// forwardAgent forwards the ssh-agent to the remote host.
+//
+// Each session still needs to request agent forwarding before it is used.
+func (c *Client) forwardAgent() error {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return ErrNoAgent
+	}
+	err := agent.ForwardToRemote(c.client, socket)
+	if err != nil {
+		return fmt.Errorf("failed to forward ssh-agent: %w", err)
+	}
+	return nil
+}
+
+// closeAgent closes the connection to the ssh-agent (if open).
+func (c *Client) closeAgent() {
+	if c.agentConn != nil {
+		_ = c.agentConn.Close()
+		c.agentConn = nil
+	}
+}
+
+// dialAgent connects to the ssh-agent from SSH_AUTH_SOCK.
+func dialAgent() (net.Conn, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, ErrNoAgent
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+	}
+	return conn, nil
+}
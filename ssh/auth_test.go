@@ -0,0 +1,241 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func writePrivateKey(t *testing.T, passphrase string) string {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	var block *pem.Block
+	if passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	}
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	err = os.WriteFile(path, pem.EncodeToMemory(block), 0600)
+	if err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return path
+}
+
+func TestClient_authMethods_NoneConfigured(t *testing.T) {
+	c := NewClient(&ClientInfo{Name: "test", Host: "host", Port: "22", User: "user"})
+	_, err := c.authMethods()
+	if !errors.Is(err, ErrNoAuthMethod) {
+		t.Errorf("expected ErrNoAuthMethod, got %v", err)
+	}
+}
+
+func TestClient_authMethods_KeyAndPassword(t *testing.T) {
+	c := NewClient(&ClientInfo{
+		Name:    "test",
+		Host:    "host",
+		Port:    "22",
+		User:    "user",
+		Pass:    "pass",
+		KeyPath: writePrivateKey(t, ""),
+	})
+	methods, err := c.authMethods()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(methods) != 2 {
+		t.Errorf("expected 2 auth methods, got %d", len(methods))
+	}
+}
+
+func TestLoadPrivateKey_Passphrase(t *testing.T) {
+	path := writePrivateKey(t, "secret")
+	_, err := loadPrivateKey(path, "secret")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_, err = loadPrivateKey(path, "")
+	if err == nil {
+		t.Errorf("expected error for missing passphrase, got nil")
+	}
+}
+
+func TestLoadPrivateKey_MissingFile(t *testing.T) {
+	_, err := loadPrivateKey(filepath.Join(t.TempDir(), "missing"), "")
+	if err == nil {
+		t.Errorf("expected error for missing key file, got nil")
+	}
+}
+
+func TestClient_authMethods_AgentNotRunning(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	c := NewClient(&ClientInfo{Name: "test", Host: "host", Port: "22", User: "user", UseAgent: true})
+	_, err := c.authMethods()
+	if !errors.Is(err, ErrNoAgent) {
+		t.Errorf("expected ErrNoAgent, got %v", err)
+	}
+}
+
+func TestClient_authMethods_Agent(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on agent socket: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		keyring := agent.NewKeyring()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	c := NewClient(&ClientInfo{Name: "test", Host: "host", Port: "22", User: "user", UseAgent: true})
+	methods, err := c.authMethods()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer c.Close()
+	if len(methods) != 1 {
+		t.Errorf("expected 1 auth method, got %d", len(methods))
+	}
+	if c.agentConn == nil {
+		t.Errorf("expected agent connection to be open")
+	}
+}
+
+func writeCertificate(t *testing.T, keyPath string, certType uint32, validBefore uint64) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatalf("failed to read key: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		t.Fatalf("failed to parse key: %v", err)
+	}
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	ca, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatalf("failed to create CA signer: %v", err)
+	}
+	cert := &ssh.Certificate{
+		Key:             signer.PublicKey(),
+		CertType:        certType,
+		ValidPrincipals: []string{"user"},
+		ValidBefore:     validBefore,
+	}
+	err = cert.SignCert(rand.Reader, ca)
+	if err != nil {
+		t.Fatalf("failed to sign certificate: %v", err)
+	}
+	err = os.WriteFile(keyPath+"-cert.pub", ssh.MarshalAuthorizedKey(cert), 0600)
+	if err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+}
+
+func TestClientInfo_CertificateExpiry(t *testing.T) {
+	keyPath := writePrivateKey(t, "")
+	info := &ClientInfo{KeyPath: keyPath}
+	_, ok, err := info.CertificateExpiry()
+	if err != nil || ok {
+		t.Fatalf("expected no certificate, got %v (ok %v)", err, ok)
+	}
+
+	validBefore := time.Now().Add(time.Hour).Truncate(time.Second)
+	writeCertificate(t, keyPath, ssh.UserCert, uint64(validBefore.Unix()))
+	if info.CertificatePath() != keyPath+"-cert.pub" {
+		t.Errorf("expected certificate next to the key, got '%s'", info.CertificatePath())
+	}
+	expiresAt, ok, err := info.CertificateExpiry()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !ok || !expiresAt.Equal(validBefore) {
+		t.Errorf("expected expiry %s, got %s (ok %v)", validBefore, expiresAt, ok)
+	}
+
+	writeCertificate(t, keyPath, ssh.UserCert, ssh.CertTimeInfinity)
+	_, ok, err = info.CertificateExpiry()
+	if err != nil || ok {
+		t.Errorf("expected certificate to never expire, got %v (ok %v)", err, ok)
+	}
+}
+
+func TestClient_authMethods_Certificate(t *testing.T) {
+	keyPath := writePrivateKey(t, "")
+	writeCertificate(t, keyPath, ssh.UserCert, ssh.CertTimeInfinity)
+	c := NewClient(&ClientInfo{Name: "test", Host: "host", Port: "22", User: "user", KeyPath: keyPath})
+	methods, err := c.authMethods()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(methods) != 1 {
+		t.Errorf("expected 1 auth method, got %d", len(methods))
+	}
+
+	writeCertificate(t, keyPath, ssh.HostCert, ssh.CertTimeInfinity)
+	_, err = c.authMethods()
+	if err == nil {
+		t.Errorf("expected error for host certificate, got nil")
+	}
+}
+
+func TestClient_authMethods_KeyboardInteractiveNoChallenge(t *testing.T) {
+	c := NewClient(&ClientInfo{Name: "test", Host: "host", Port: "22", User: "user", KeyboardInteractive: true})
+	_, err := c.authMethods()
+	if !errors.Is(err, ErrNoKeyboardInteractive) {
+		t.Errorf("expected ErrNoKeyboardInteractive, got %v", err)
+	}
+}
+
+func TestClient_keyboardInteractive(t *testing.T) {
+	var asked []string
+	challenge := func(host, name, instruction string, questions []string, echos []bool) ([]string, error) {
+		if host != "test" {
+			t.Errorf("expected host 'test', got '%s'", host)
+		}
+		asked = questions
+		return []string{"123456"}, nil
+	}
+	c := NewClient(
+		&ClientInfo{Name: "test", Host: "host", Port: "22", User: "user", Pass: "pass", KeyboardInteractive: true},
+		WithKeyboardInteractive(challenge),
+	)
+	answers, err := c.keyboardInteractive("", "", []string{"Password: ", "Verification code: "}, []bool{false, false})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(asked) != 1 || asked[0] != "Verification code: " {
+		t.Errorf("expected only the verification code to be asked, got %v", asked)
+	}
+	if len(answers) != 2 || answers[0] != "pass" || answers[1] != "123456" {
+		t.Errorf("expected answers [pass 123456], got %v", answers)
+	}
+}
@@ -0,0 +1,228 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// BecomeSudo becomes the user with sudo, the password is read from stdin.
+	BecomeSudo = "sudo"
+	// BecomeSu becomes the user with su, which requires a pseudo-terminal.
+	BecomeSu = "su"
+	// BecomeDoas becomes the user with doas, which requires a pseudo-terminal.
+	BecomeDoas = "doas"
+)
+
+// redactedPassword replaces the become password if it ends up in the output.
+const redactedPassword = "********"
+
+// ValidateBecomeMethod returns an error when the become method is not supported (empty is sudo).
+func ValidateBecomeMethod(method string) error {
+	switch method {
+	case "", BecomeSudo, BecomeSu, BecomeDoas:
+		return nil
+	}
+	return fmt.Errorf("invalid become method %q: must be %s, %s or %s", method, BecomeSudo, BecomeSu, BecomeDoas)
+}
+
+// WithBecome runs the command as the user (root when empty) with the become method of the host.
+//
+// The become password of the host (or its password) answers the password prompt over stdin and
+// the data from WithStdin is only written once the command runs as the user. The password never
+// appears in the output.
+func WithBecome(user string) ExecOption {
+	return func(o *execOptions) {
+		o.become = &becomeOptions{user: user}
+	}
+}
+
+type becomeOptions struct {
+	user string
+}
+
+// becomer escalates the privileges of a command and answers its password prompt.
+//
+// The command is wrapped so it first prints a marker once it runs as the user. Until the marker
+// is seen the output is held back and watched for the password prompt.
+type becomer struct {
+	method   string
+	user     string
+	password string
+	token    string
+	prompt   *regexp.Regexp
+
+	// signalled for every password prompt
+	prompts chan struct{}
+	// closed once the command runs as the user
+	ready chan struct{}
+
+	mx      sync.Mutex
+	out     io.Writer
+	pending []byte
+	scanned int
+	running bool
+}
+
+// newBecomer creates the becomer for the client.
+func (c *Client) newBecomer(options *becomeOptions) (*becomer, error) {
+	err := ValidateBecomeMethod(c.info.BecomeMethod)
+	if err != nil {
+		return nil, err
+	}
+	if shell := c.info.Shell(); shell != ShellPOSIX {
+		return nil, fmt.Errorf("become is not supported on hosts with the %s shell", shell)
+	}
+	b := &becomer{
+		method:   c.info.BecomeMethod,
+		user:     options.user,
+		password: c.info.BecomePass,
+		token:    "sshai-become-" + randomSuffix(),
+		prompts:  make(chan struct{}, 1),
+		ready:    make(chan struct{}),
+	}
+	if b.method == "" {
+		b.method = BecomeSudo
+	}
+	if b.password == "" {
+		b.password = c.info.Pass
+	}
+	if b.method == BecomeSudo {
+		b.prompt = regexp.MustCompile(regexp.QuoteMeta(b.sudoPrompt()) + `\s*$`)
+	} else {
+		// su and doas prompts cannot be changed (e.g. "Password:" or "doas (user@host) password:")
+		b.prompt = regexp.MustCompile(`(?i)password[^\n]*:\s*$`)
+	}
+	return b, nil
+}
+
+// needsPTY returns true when the become method reads the password from a terminal.
+func (b *becomer) needsPTY() bool {
+	return b.method != BecomeSudo
+}
+
+// sudoPrompt is the password prompt sudo is told to use.
+func (b *becomer) sudoPrompt() string {
+	return b.token + "-password:"
+}
+
+// readyMarker is printed by the command once it runs as the user.
+func (b *becomer) readyMarker() string {
+	return b.token + "-ready"
+}
+
+// command wraps the command to run as the user.
+func (b *becomer) command(cmd string) string {
+	script := shellQuote(fmt.Sprintf("echo %s >&2; %s", b.readyMarker(), cmd))
+	switch b.method {
+	case BecomeSu:
+		user := b.user
+		if user == "" {
+			user = "root"
+		}
+		return fmt.Sprintf("su %s -c %s", shellQuote(user), script)
+	case BecomeDoas:
+		if b.user == "" {
+			return fmt.Sprintf("doas sh -c %s", script)
+		}
+		return fmt.Sprintf("doas -u %s sh -c %s", shellQuote(b.user), script)
+	}
+	if b.user == "" {
+		return fmt.Sprintf("sudo -S -p %s -- sh -c %s", shellQuote(b.sudoPrompt()), script)
+	}
+	return fmt.Sprintf("sudo -S -p %s -u %s -- sh -c %s", shellQuote(b.sudoPrompt()), shellQuote(b.user), script)
+}
+
+// writer returns the writer for the stream that the prompt and the marker are written to.
+func (b *becomer) writer(out io.Writer) io.Writer {
+	b.out = out
+	return b
+}
+
+// Write holds back the output until the command runs as the user.
+func (b *becomer) Write(p []byte) (int, error) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.running {
+		return b.out.Write(p)
+	}
+	b.pending = append(b.pending, p...)
+
+	marker := []byte(b.readyMarker())
+	idx := bytes.Index(b.pending, marker)
+	if idx < 0 {
+		if b.prompt.Match(b.pending[b.scanned:]) {
+			b.scanned = len(b.pending)
+			select {
+			case b.prompts <- struct{}{}:
+			default:
+			}
+		}
+		return len(p), nil
+	}
+	b.running = true
+	close(b.ready)
+	rest := b.pending[idx+len(marker):]
+	rest = bytes.TrimPrefix(bytes.TrimPrefix(rest, []byte{'\r'}), []byte{'\n'})
+	b.pending = nil
+	if len(rest) > 0 {
+		_, err := b.out.Write(rest)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// finish writes the held back output (without the prompts) when the command never ran as the user.
+func (b *becomer) finish() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.running || len(b.pending) == 0 {
+		return
+	}
+	output := strings.ReplaceAll(string(b.pending), b.sudoPrompt(), "")
+	_, _ = io.WriteString(b.out, strings.TrimLeft(output, "\r\n"))
+	b.pending = nil
+}
+
+// feed answers the password prompt and writes the data to stdin once the command runs as the user.
+//
+// A second prompt means the password was wrong, stdin is closed so the command fails instead of
+// waiting for another password.
+func (b *becomer) feed(stdin io.WriteCloser, data []byte, done <-chan struct{}) {
+	defer stdin.Close()
+	answered := false
+	for {
+		select {
+		case <-b.prompts:
+			if answered || b.password == "" {
+				return
+			}
+			answered = true
+			_, err := io.WriteString(stdin, b.password+"\n")
+			if err != nil {
+				return
+			}
+		case <-b.ready:
+			if len(data) > 0 {
+				_, _ = stdin.Write(data)
+			}
+			return
+		case <-done:
+			return
+		}
+	}
+}
+
+// redact removes the password from the output.
+func (b *becomer) redact(output string) string {
+	if b.password == "" {
+		return output
+	}
+	return strings.ReplaceAll(output, b.password, redactedPassword)
+}
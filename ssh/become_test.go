@@ -0,0 +1,194 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	sudoPromptPattern  = regexp.MustCompile(`-p '([^']*)'`)
+	readyMarkerPattern = regexp.MustCompile(`echo (\S+) >&2`)
+)
+
+// becomeHandler emulates sudo -S and su with the password "secret", the nopasswd user needs no password.
+//
+// Once the password is accepted the command writes "running as root" followed by its input.
+func becomeHandler(cmd string, ch ssh.Channel) uint32 {
+	marker := readyMarkerPattern.FindStringSubmatch(cmd)
+	if marker == nil {
+		return echoHandler(cmd, ch)
+	}
+	var prompt string
+	var out io.Writer
+	switch {
+	case strings.HasPrefix(cmd, "sudo "):
+		prompt = sudoPromptPattern.FindStringSubmatch(cmd)[1]
+		out = ch.Stderr()
+	case strings.HasPrefix(cmd, "su "):
+		// su reads the password from the terminal, which combines the streams
+		prompt = "Password: "
+		out = ch
+	default:
+		return 127
+	}
+	if !strings.Contains(cmd, "'nopasswd'") {
+		for attempt := 1; ; attempt++ {
+			_, _ = io.WriteString(out, prompt)
+			password, err := readLine(ch)
+			if err == nil && password == "secret" {
+				break
+			}
+			if err != nil || attempt == 3 {
+				_, _ = io.WriteString(out, "\nsudo: no password was provided\n")
+				return 1
+			}
+			_, _ = io.WriteString(out, "\nSorry, try again.\n")
+		}
+	}
+	_, _ = io.WriteString(out, marker[1]+"\n")
+	input, _ := io.ReadAll(ch)
+	_, _ = io.WriteString(ch, "running as root\n"+string(input))
+	return 0
+}
+
+// readLine reads a single line from the reader without reading past it.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		_, err := r.Read(b)
+		if err != nil {
+			return "", err
+		}
+		if b[0] == '\n' {
+			return string(line), nil
+		}
+		line = append(line, b[0])
+	}
+}
+
+func TestClient_ExecBecome(t *testing.T) {
+	server := newTestServer(t, becomeHandler)
+	server.info.BecomePass = "secret"
+	client := connectTestClient(t, server)
+
+	var lines []string
+	result, err := client.Exec(context.Background(), "cat", WithBecome(""), WithStdin([]byte("the secret input\n")), WithOutputLines(func(stream string, line string) {
+		lines = append(lines, stream+": "+line)
+	}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (%s)", result.ExitCode, result.Stderr)
+	}
+	// the input is only written once the password was accepted and the password is redacted
+	if result.Stdout != "running as root\nthe ******** input\n" {
+		t.Errorf("expected the input after the password, got %q", result.Stdout)
+	}
+	if result.Stderr != "" {
+		t.Errorf("expected the prompt and marker to be removed, got %q", result.Stderr)
+	}
+	if !slices.Equal(lines, []string{"stdout: running as root", "stdout: the ******** input"}) {
+		t.Errorf("expected the streamed lines without the prompt, got %q", lines)
+	}
+
+	// the password is redacted from the output written to the writers as well
+	var stdout, stderr bytes.Buffer
+	_, err = client.Exec(context.Background(), "cat", WithBecome(""), WithStdin([]byte("the secret input\n")), WithOutput(&stdout, &stderr))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stdout.String() != "running as root\nthe ******** input\n" || stderr.String() != "" {
+		t.Errorf("expected the redacted output without the prompt, got %q and %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestClient_ExecBecomeWrongPassword(t *testing.T) {
+	server := newTestServer(t, becomeHandler)
+	server.info.BecomePass = "wrong"
+	client := connectTestClient(t, server)
+
+	result, err := client.Exec(context.Background(), "id", WithBecome(""))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.ExitCode != 1 {
+		t.Errorf("expected exit code 1, got %d", result.ExitCode)
+	}
+	if !strings.Contains(result.Stderr, "Sorry, try again.") {
+		t.Errorf("expected the sudo error, got %q", result.Stderr)
+	}
+	if strings.Contains(result.Stderr, "sshai-become-") || strings.Contains(result.Stderr, "wrong") {
+		t.Errorf("expected no prompt or password in the output, got %q", result.Stderr)
+	}
+	if result.Stdout != "" {
+		t.Errorf("expected the command to not run, got %q", result.Stdout)
+	}
+}
+
+func TestClient_ExecBecomeNoPassword(t *testing.T) {
+	server := newTestServer(t, becomeHandler)
+	server.info.BecomePass = "secret"
+	client := connectTestClient(t, server)
+
+	// no prompt, so the password must not end up in the input of the command
+	result, err := client.Exec(context.Background(), "cat", WithBecome("nopasswd"), WithStdin([]byte("input\n")))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Stdout != "running as root\ninput\n" {
+		t.Errorf("expected only the input, got %q", result.Stdout)
+	}
+}
+
+func TestClient_ExecBecomeSu(t *testing.T) {
+	server := newTestServer(t, becomeHandler)
+	server.info.BecomeMethod = BecomeSu
+	server.info.BecomePass = "secret"
+	client := connectTestClient(t, server)
+
+	result, err := client.Exec(context.Background(), "id", WithBecome("postgres"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Stdout != "running as root\n" {
+		t.Errorf("expected the output after the marker, got %q", result.Stdout)
+	}
+	if !result.PTY || len(server.PTYs()) != 1 {
+		t.Errorf("expected su to run in a pty, got %v", server.PTYs())
+	}
+}
+
+func TestBecomer_Command(t *testing.T) {
+	tests := []struct {
+		method   string
+		user     string
+		expected string
+	}{
+		{BecomeSudo, "", `sudo -S -p 'T-password:' -- sh -c 'echo T-ready >&2; echo '\''hi'\'''`},
+		{BecomeSudo, "postgres", `sudo -S -p 'T-password:' -u 'postgres' -- sh -c 'echo T-ready >&2; echo '\''hi'\'''`},
+		{BecomeSu, "", `su 'root' -c 'echo T-ready >&2; echo '\''hi'\'''`},
+		{BecomeDoas, "postgres", `doas -u 'postgres' sh -c 'echo T-ready >&2; echo '\''hi'\'''`},
+	}
+	for _, tt := range tests {
+		b := &becomer{method: tt.method, user: tt.user, token: "T"}
+		if cmd := b.command("echo 'hi'"); cmd != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, cmd)
+		}
+	}
+	client := NewClient(&ClientInfo{OS: OSInfo{Shell: ShellPowerShell}})
+	if _, err := client.newBecomer(&becomeOptions{}); err == nil {
+		t.Error("expected become to fail on a powershell host")
+	}
+	if err := ValidateBecomeMethod("runas"); err == nil {
+		t.Error("expected runas to be invalid")
+	}
+}
@@ -0,0 +1,466 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ExecResult is the result of running a command on the remote SSH server.
+type ExecResult struct {
+	Stdout   string        `json:"stdout"`
+	Stderr   string        `json:"stderr"`
+	ExitCode int           `json:"exit_code"`
+	Signal   string        `json:"signal,omitempty"`
+	Duration time.Duration `json:"-"`
+
+	// PTY is true when the command ran in a pseudo-terminal, the output of both streams is then in Stdout
+	// with the ANSI escape sequences removed.
+	PTY bool `json:"pty,omitempty"`
+}
+
+// Err returns an error when the command did not exit successfully.
+func (r *ExecResult) Err() error {
+	if r.ExitCode == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("exited with status %d", r.ExitCode)
+	if r.Signal != "" {
+		msg = fmt.Sprintf("killed by signal %s", r.Signal)
+	}
+	if stderr := strings.TrimSpace(r.Stderr); stderr != "" {
+		msg = fmt.Sprintf("%s: %s", msg, stderr)
+	}
+	return errors.New(msg)
+}
+
+// ExecOption is an option for running a command.
+type ExecOption func(*execOptions)
+
+type execOptions struct {
+	onLine func(stream string, line string)
+	pty    *ptyOptions
+	stdin  []byte
+	become *becomeOptions
+	env    map[string]string
+	dir    string
+
+	noCommandTimeout bool
+	discardOutput    bool
+	stdout           io.Writer
+	stderr           io.Writer
+	signals          <-chan string
+}
+
+type ptyOptions struct {
+	term   string
+	width  int
+	height int
+}
+
+const (
+	// DefaultPTYTerm is the default TERM of the pseudo-terminal.
+	DefaultPTYTerm = "xterm"
+	// DefaultPTYWidth is the default number of columns of the pseudo-terminal.
+	DefaultPTYWidth = 80
+	// DefaultPTYHeight is the default number of rows of the pseudo-terminal.
+	DefaultPTYHeight = 24
+)
+
+// WithOutputLines calls onLine with each line of output ("stdout" or "stderr" stream) while the command runs.
+//
+// The calls are serialized, so onLine is never called concurrently.
+func WithOutputLines(onLine func(stream string, line string)) ExecOption {
+	return func(o *execOptions) {
+		o.onLine = onLine
+	}
+}
+
+// WithDiscardOutput does not keep the output in the result, for long running commands whose output
+// is consumed with WithOutputLines instead.
+func WithDiscardOutput() ExecOption {
+	return func(o *execOptions) {
+		o.discardOutput = true
+	}
+}
+
+// WithOutput writes the output of the streams to the writers while the command runs instead of
+// keeping it in the result, so the output does not need to fit in memory.
+//
+// The output is written the same as it would be in the result: without the ANSI escape sequences in
+// a pseudo-terminal and with the become password redacted.
+func WithOutput(stdout io.Writer, stderr io.Writer) ExecOption {
+	return func(o *execOptions) {
+		o.stdout = stdout
+		o.stderr = stderr
+	}
+}
+
+// withoutCommandTimeout does not apply the command timeout of the host, for background jobs that
+// run until they finish or are killed.
+func withoutCommandTimeout() ExecOption {
+	return func(o *execOptions) {
+		o.noCommandTimeout = true
+	}
+}
+
+// WithSignals sends the signals (e.g. "INT" or "TERM", see ValidateSignal) to the command while it runs.
+func WithSignals(signals <-chan string) ExecOption {
+	return func(o *execOptions) {
+		o.signals = signals
+	}
+}
+
+// WithStdin writes the data to the standard input of the command, which is closed once all the data is written.
+func WithStdin(data []byte) ExecOption {
+	return func(o *execOptions) {
+		o.stdin = data
+	}
+}
+
+// WithEnv sets the environment variables of the command, on top of the environment of the host.
+func WithEnv(env map[string]string) ExecOption {
+	return func(o *execOptions) {
+		o.env = env
+	}
+}
+
+// WithDir runs the command in the working directory.
+func WithDir(dir string) ExecOption {
+	return func(o *execOptions) {
+		o.dir = dir
+	}
+}
+
+// WithPTY runs the command in a pseudo-terminal with the TERM and size (defaults are used when empty or zero).
+//
+// This is for commands that refuse to run without a terminal (e.g. sudo with requiretty).
+func WithPTY(term string, width int, height int) ExecOption {
+	return func(o *execOptions) {
+		if term == "" {
+			term = DefaultPTYTerm
+		}
+		if width <= 0 {
+			width = DefaultPTYWidth
+		}
+		if height <= 0 {
+			height = DefaultPTYHeight
+		}
+		o.pty = &ptyOptions{term: term, width: width, height: height}
+	}
+}
+
+// Exec runs a command on the remote SSH server.
+//
+// A command that runs to completion returns its result even when it exits with a non-zero status,
+// an error is only returned when the command could not be run. The command timeout of the host
+// applies, or the deadline of the context when it is earlier. When the context is done the remote process
+// is signalled to terminate and the session is closed.
+//
+// The environment is set with the SSH protocol when the server accepts it, otherwise (and when
+// becoming another user) the command is prefixed to set it in the shell.
+func (c *Client) Exec(ctx context.Context, cmd string, opts ...ExecOption) (*ExecResult, error) {
+	if c.client == nil {
+		return nil, ErrNotConnected
+	}
+	var options execOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	env := make(map[string]string, len(c.info.Env)+len(options.env))
+	maps.Copy(env, c.info.Env)
+	maps.Copy(env, options.env)
+	err := ValidateEnv(env)
+	if err != nil {
+		return nil, err
+	}
+	if !options.noCommandTimeout {
+		var cancel context.CancelFunc
+		ctx, cancel, err = withTimeout(ctx, c.info.CommandTimeout)
+		if err != nil {
+			return nil, err
+		}
+		defer cancel()
+	}
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	var become *becomer
+	if options.become != nil {
+		become, err = c.newBecomer(options.become)
+		if err != nil {
+			return nil, err
+		}
+		if become.needsPTY() && options.pty == nil {
+			WithPTY("", 0, 0)(&options)
+		}
+		if onLine := options.onLine; onLine != nil {
+			options.onLine = func(stream string, line string) {
+				onLine(stream, become.redact(line))
+			}
+		}
+	}
+
+	if c.info.ForwardAgent {
+		err = agent.RequestAgentForwarding(session)
+		if err != nil {
+			return nil, fmt.Errorf("failed to request agent forwarding: %w", err)
+		}
+	}
+
+	// sudo and friends reset the environment, so it is only kept when set by the shell
+	var shellEnv map[string]string
+	if become != nil || !setenv(session, env) {
+		shellEnv = env
+	}
+	cmd, err = withShellPrefix(c.info.Shell(), cmd, options.dir, shellEnv)
+	if err != nil {
+		return nil, err
+	}
+	if become != nil {
+		cmd = become.command(cmd)
+	}
+
+	if options.pty != nil {
+		modes := ssh.TerminalModes{
+			// input is not echoed back into the output
+			ssh.ECHO:          0,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}
+		err = session.RequestPty(options.pty.term, options.pty.height, options.pty.width, modes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to request pty: %w", err)
+		}
+		if onLine := options.onLine; onLine != nil {
+			options.onLine = func(stream string, line string) {
+				onLine(stream, stripANSI(line))
+			}
+		}
+	}
+
+	// closed once the command has finished or is cancelled
+	finished := make(chan struct{})
+	defer close(finished)
+	if become != nil {
+		stdin, err := session.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		go become.feed(stdin, options.stdin, finished)
+	} else if options.stdin != nil {
+		session.Stdin = bytes.NewReader(options.stdin)
+	}
+
+	var stdout, stderr bytes.Buffer
+	var stdoutKept, stderrKept io.Writer = &stdout, &stderr
+	var outputWriters []*outputWriter
+	if options.stdout != nil && options.stderr != nil {
+		// the output is cleaned up line by line as it is not kept to clean it up once finished
+		var clean func(line string) string
+		if options.pty != nil || become != nil {
+			clean = func(line string) string {
+				if options.pty != nil {
+					line = stripANSI(strings.TrimSuffix(line, "\r"))
+				}
+				if become != nil {
+					line = become.redact(line)
+				}
+				return line
+			}
+		}
+		stdoutWriter := &outputWriter{out: options.stdout, clean: clean}
+		stderrWriter := &outputWriter{out: options.stderr, clean: clean}
+		outputWriters = append(outputWriters, stdoutWriter, stderrWriter)
+		stdoutKept, stderrKept = stdoutWriter, stderrWriter
+	}
+	if options.discardOutput {
+		stdoutKept, stderrKept = io.Discard, io.Discard
+	}
+	session.Stdout = stdoutKept
+	session.Stderr = stderrKept
+	var lineWriters []*lineWriter
+	if options.onLine != nil {
+		var mx sync.Mutex
+		stdoutLines := &lineWriter{stream: "stdout", mx: &mx, onLine: options.onLine}
+		stderrLines := &lineWriter{stream: "stderr", mx: &mx, onLine: options.onLine}
+		lineWriters = append(lineWriters, stdoutLines, stderrLines)
+		session.Stdout = io.MultiWriter(stdoutKept, stdoutLines)
+		session.Stderr = io.MultiWriter(stderrKept, stderrLines)
+	}
+	if become != nil {
+		// the prompt and the marker are on stderr, unless both streams are the terminal
+		if options.pty != nil {
+			session.Stdout = become.writer(session.Stdout)
+		} else {
+			session.Stderr = become.writer(session.Stderr)
+		}
+	}
+	start := time.Now()
+	err = session.Start(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// buffered so the wait finishes even when the command is cancelled
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+wait:
+	for {
+		select {
+		case err = <-done:
+			// the output has been copied once the wait returns
+			if become != nil {
+				become.finish()
+			}
+			for _, w := range lineWriters {
+				w.Flush()
+			}
+			for _, w := range outputWriters {
+				w.Flush()
+			}
+			break wait
+		case signal := <-options.signals:
+			_ = session.Signal(ssh.Signal(signal))
+		case <-ctx.Done():
+			// not every server supports signals, closing the session is what ends the command on those
+			_ = session.Signal(ssh.SIGTERM)
+			_ = session.Close()
+			return nil, fmt.Errorf("command cancelled: %w", ctx.Err())
+		}
+	}
+
+	result := &ExecResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+	}
+	if options.pty != nil {
+		result.Stdout = stripANSI(strings.ReplaceAll(result.Stdout, "\r\n", "\n"))
+		result.PTY = true
+	}
+	if become != nil {
+		result.Stdout = become.redact(result.Stdout)
+		result.Stderr = become.redact(result.Stderr)
+	}
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitStatus()
+		result.Signal = exitErr.Signal()
+	} else if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// signals are the signals that can be sent to a command (RFC 4254 section 6.10).
+var signals = []string{"ABRT", "ALRM", "FPE", "HUP", "ILL", "INT", "KILL", "PIPE", "QUIT", "SEGV", "TERM", "USR1", "USR2"}
+
+// ValidateSignal returns an error when the signal cannot be sent to a command.
+func ValidateSignal(signal string) error {
+	if !slices.Contains(signals, signal) {
+		return fmt.Errorf("invalid signal %q: must be one of %s", signal, strings.Join(signals, ", "))
+	}
+	return nil
+}
+
+// setenv sets the environment of the session, returns false when the server does not accept it.
+//
+// Most servers only accept a few variables (AcceptEnv in OpenSSH).
+func setenv(session *ssh.Session, env map[string]string) bool {
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		err := session.Setenv(name, env[name])
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// lineWriter splits the written output into lines.
+type lineWriter struct {
+	stream string
+	mx     *sync.Mutex
+	onLine func(stream string, line string)
+
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		idx := bytes.IndexByte(w.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		w.emit(string(bytes.TrimSuffix(w.partial[:idx], []byte{'\r'})))
+		w.partial = w.partial[idx+1:]
+	}
+	return len(p), nil
+}
+
+// Flush emits the last line when the output did not end with a newline.
+func (w *lineWriter) Flush() {
+	if len(w.partial) > 0 {
+		w.emit(string(w.partial))
+		w.partial = nil
+	}
+}
+
+func (w *lineWriter) emit(line string) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	w.onLine(w.stream, line)
+}
+
+// outputWriter writes the output to the writer with each line cleaned up, the output is written as is
+// without a clean function.
+type outputWriter struct {
+	out   io.Writer
+	clean func(line string) string
+
+	partial []byte
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	if w.clean == nil {
+		return w.out.Write(p)
+	}
+	w.partial = append(w.partial, p...)
+	for {
+		idx := bytes.IndexByte(w.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		_, err := io.WriteString(w.out, w.clean(string(w.partial[:idx]))+"\n")
+		if err != nil {
+			return 0, err
+		}
+		w.partial = w.partial[idx+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes the last line when the output did not end with a newline.
+func (w *outputWriter) Flush() {
+	if w.clean != nil && len(w.partial) > 0 {
+		_, _ = io.WriteString(w.out, w.clean(string(w.partial)))
+		w.partial = nil
+	}
+}
@@ -0,0 +1,398 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrJobNotFound returned when there is no job with the ID.
+var ErrJobNotFound = errors.New("job not found")
+
+const (
+	// DefaultJobOutputSize is the default number of bytes of output that is kept for a job.
+	DefaultJobOutputSize = 1 << 20
+	// MaxJobOutputSize is the largest number of bytes of output that can be kept for a job.
+	MaxJobOutputSize = 64 << 20
+	// DefaultJobRetention is the default time a finished job and its output are kept.
+	DefaultJobRetention = time.Hour
+	// maxFinishedJobs is the number of finished jobs that are kept, the oldest are removed first.
+	maxFinishedJobs = 100
+)
+
+const (
+	// JobRunning is the status of a job whose command is still running.
+	JobRunning = "running"
+	// JobExited is the status of a job whose command exited.
+	JobExited = "exited"
+	// JobKilled is the status of a job whose command was killed.
+	JobKilled = "killed"
+	// JobFailed is the status of a job whose command could not be run.
+	JobFailed = "failed"
+)
+
+// JobInfo is the state of a background job.
+type JobInfo struct {
+	ID         string     `json:"id"`
+	Host       string     `json:"host"`
+	Command    string     `json:"command"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	Signal     string     `json:"signal,omitempty"`
+	Error      string     `json:"error,omitempty"`
+
+	// OutputSize is the number of bytes of output so far, which is the offset of the next output.
+	OutputSize int64 `json:"output_size"`
+}
+
+// JobOutput is part of the output of a background job.
+type JobOutput struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Output string `json:"output"`
+
+	// Offset is the offset of the output, which is later than the requested offset when that output was dropped.
+	Offset int64 `json:"offset"`
+	// NextOffset is the offset to read the following output from.
+	NextOffset int64 `json:"next_offset"`
+	// Dropped is the number of bytes between the requested offset and the output that are no longer kept.
+	Dropped int64 `json:"dropped,omitempty"`
+}
+
+// job is a command running in the background on a connection from the manager.
+type job struct {
+	id        string
+	host      string
+	command   string
+	startedAt time.Time
+
+	output  *outputBuffer
+	signals chan string
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mx         sync.Mutex
+	finishedAt time.Time
+	result     *ExecResult
+	err        error
+	killed     bool
+}
+
+// StartJob runs the command in the background on the connection to the SSH server.
+//
+// The job takes over the connection, release is called once the command finishes. The last
+// outputSize bytes (DefaultJobOutputSize when zero, at most MaxJobOutputSize) of the combined output
+// are kept. The command timeout of the host does not apply, the job runs until the command finishes, it is killed with SignalJob or the manager is
+// closed. Finished jobs are removed after the job retention of the manager.
+func (m *Manager) StartJob(client *Client, release func(), cmd string, outputSize int, opts ...ExecOption) (*JobInfo, error) {
+	if client.client == nil {
+		release()
+		return nil, ErrNotConnected
+	}
+	err := ValidateJobOutputSize(outputSize)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	if outputSize == 0 {
+		outputSize = DefaultJobOutputSize
+	}
+
+	m.mx.Lock()
+	if m.closed {
+		m.mx.Unlock()
+		release()
+		return nil, ErrManagerClosed
+	}
+	m.jobID++
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		id:        fmt.Sprintf("job-%d", m.jobID),
+		host:      client.info.Name,
+		command:   cmd,
+		startedAt: time.Now(),
+		output:    newOutputBuffer(outputSize),
+		signals:   make(chan string),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	m.pruneJobsLocked(j.startedAt)
+	m.jobs[j.id] = j
+	m.mx.Unlock()
+
+	opts = append(slices.Clone(opts),
+		withoutCommandTimeout(),
+		WithDiscardOutput(),
+		WithOutputLines(func(stream string, line string) {
+			j.output.WriteLine(line)
+		}),
+		WithSignals(j.signals),
+	)
+	go func() {
+		defer close(j.done)
+		defer release()
+		result, err := client.Exec(ctx, cmd, opts...)
+		cancel()
+
+		j.mx.Lock()
+		defer j.mx.Unlock()
+		j.finishedAt = time.Now()
+		j.result = result
+		j.err = err
+	}()
+	info := j.info()
+	return &info, nil
+}
+
+// Jobs returns the state of the jobs.
+func (m *Manager) Jobs() []JobInfo {
+	m.mx.Lock()
+	jobs := make([]*job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+	m.mx.Unlock()
+
+	infos := make([]JobInfo, 0, len(jobs))
+	for _, j := range jobs {
+		infos = append(infos, j.info())
+	}
+	slices.SortFunc(infos, func(a, b JobInfo) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+	return infos
+}
+
+// Job returns the state of the job.
+func (m *Manager) Job(id string) (*JobInfo, error) {
+	j, err := m.job(id)
+	if err != nil {
+		return nil, err
+	}
+	info := j.info()
+	return &info, nil
+}
+
+// JobOutput returns at most limit bytes (everything when zero) of the output of the job from the offset.
+func (m *Manager) JobOutput(id string, offset int64, limit int) (*JobOutput, error) {
+	j, err := m.job(id)
+	if err != nil {
+		return nil, err
+	}
+	// the status is read first so output after a finished status is never missed
+	status := j.info().Status
+	data, start, next := j.output.Read(offset, limit)
+	output := &JobOutput{
+		ID:         j.id,
+		Status:     status,
+		Output:     string(data),
+		Offset:     start,
+		NextOffset: next,
+	}
+	if start > offset {
+		output.Dropped = start - offset
+	}
+	return output, nil
+}
+
+// SignalJob sends the signal to the command of the job.
+//
+// The KILL signal also closes the session, which ends the command on servers that ignore signals.
+func (m *Manager) SignalJob(id string, signal string) (*JobInfo, error) {
+	err := ValidateSignal(signal)
+	if err != nil {
+		return nil, err
+	}
+	j, err := m.job(id)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case j.signals <- signal:
+	case <-j.done:
+		return nil, fmt.Errorf("job %s is no longer running", id)
+	}
+	if signal == "KILL" {
+		j.kill()
+	}
+	info := j.info()
+	return &info, nil
+}
+
+// ValidateJobOutputSize returns an error when the number of bytes of output to keep for a job is out of range.
+func ValidateJobOutputSize(outputSize int) error {
+	if outputSize < 0 || outputSize > MaxJobOutputSize {
+		return fmt.Errorf("invalid output size %d: must be between 0 and %d bytes", outputSize, MaxJobOutputSize)
+	}
+	return nil
+}
+
+// pruneJobs removes the finished jobs that are kept longer than the job retention.
+func (m *Manager) pruneJobs() {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.pruneJobsLocked(time.Now())
+}
+
+// pruneJobsLocked removes the finished jobs that are kept longer than the job retention, and the
+// oldest finished jobs over the maximum number that are kept. Running jobs are never removed.
+//
+// The manager must be locked.
+func (m *Manager) pruneJobsLocked(now time.Time) {
+	var finished []*job
+	for id, j := range m.jobs {
+		finishedAt := j.finished()
+		if finishedAt.IsZero() {
+			continue
+		}
+		if m.jobRetention > 0 && now.Sub(finishedAt) >= m.jobRetention {
+			delete(m.jobs, id)
+			continue
+		}
+		finished = append(finished, j)
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	slices.SortFunc(finished, func(a, b *job) int {
+		return a.finished().Compare(b.finished())
+	})
+	for _, j := range finished[:len(finished)-maxFinishedJobs] {
+		delete(m.jobs, j.id)
+	}
+}
+
+// job returns the job with the ID.
+func (m *Manager) job(id string) (*job, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	return j, nil
+}
+
+// killJobs kills all the running jobs and waits for them to finish.
+func (m *Manager) killJobs() {
+	m.mx.Lock()
+	jobs := make([]*job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+	m.mx.Unlock()
+	for _, j := range jobs {
+		j.kill()
+	}
+}
+
+// kill cancels the command and waits for it to finish.
+func (j *job) kill() {
+	j.mx.Lock()
+	if j.finishedAt.IsZero() {
+		j.killed = true
+	}
+	j.mx.Unlock()
+	j.cancel()
+	<-j.done
+}
+
+// finished returns when the command of the job finished, zero while it is running.
+func (j *job) finished() time.Time {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+	return j.finishedAt
+}
+
+// info returns the state of the job.
+func (j *job) info() JobInfo {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+	info := JobInfo{
+		ID:         j.id,
+		Host:       j.host,
+		Command:    j.command,
+		Status:     JobRunning,
+		StartedAt:  j.startedAt,
+		OutputSize: j.output.Size(),
+	}
+	if j.finishedAt.IsZero() {
+		return info
+	}
+	finishedAt := j.finishedAt
+	info.FinishedAt = &finishedAt
+	switch {
+	case j.killed:
+		info.Status = JobKilled
+	case j.err != nil:
+		info.Status = JobFailed
+		info.Error = j.err.Error()
+	default:
+		info.Status = JobExited
+		exitCode := j.result.ExitCode
+		info.ExitCode = &exitCode
+		info.Signal = j.result.Signal
+		if info.Signal != "" {
+			info.Status = JobKilled
+		}
+	}
+	return info
+}
+
+// outputBuffer keeps the last bytes of output with their offset in the whole output.
+//
+// Up to twice the maximum size is buffered so the dropped output is not moved for every line.
+type outputBuffer struct {
+	mx    sync.Mutex
+	max   int
+	data  []byte
+	start int64
+}
+
+func newOutputBuffer(max int) *outputBuffer {
+	return &outputBuffer{max: max}
+}
+
+// WriteLine appends the line to the output, dropping the oldest output over the maximum size.
+func (b *outputBuffer) WriteLine(line string) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.data = append(b.data, line...)
+	b.data = append(b.data, '\n')
+	if len(b.data) > 2*b.max {
+		drop := len(b.data) - b.max
+		b.data = append(b.data[:0], b.data[drop:]...)
+		b.start += int64(drop)
+	}
+}
+
+// Size returns the number of bytes written.
+func (b *outputBuffer) Size() int64 {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.start + int64(len(b.data))
+}
+
+// Read returns at most limit bytes (everything when zero) from the offset, with the offset of the returned
+// data (later than offset when that data was dropped) and the offset following it.
+func (b *outputBuffer) Read(offset int64, limit int) ([]byte, int64, int64) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	kept := b.data[max(0, len(b.data)-b.max):]
+	start := b.start + int64(len(b.data)-len(kept))
+	end := b.start + int64(len(b.data))
+	offset = max(offset, start)
+	if offset > end {
+		return nil, end, end
+	}
+	data := kept[offset-start:]
+	if limit > 0 && len(data) > limit {
+		data = data[:limit]
+	}
+	return slices.Clone(data), offset, offset + int64(len(data))
+}
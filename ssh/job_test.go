@@ -0,0 +1,235 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// waitForJob waits for the job to finish and returns its final state.
+func waitForJob(t *testing.T, manager *Manager, id string) *JobInfo {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		info, err := manager.Job(id)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if info.Status != JobRunning {
+			return info
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected job %s to finish", id)
+	return nil
+}
+
+func TestManager_StartJob(t *testing.T) {
+	server := newTestServer(t, func(cmd string, ch ssh.Channel) uint32 {
+		for i := 1; i <= 3; i++ {
+			_, _ = fmt.Fprintf(ch, "line %d\n", i)
+		}
+		return 3
+	})
+	manager := NewManager()
+	defer manager.Close()
+
+	client, release, err := manager.Get(context.Background(), server.info, nil, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	job, err := manager.StartJob(client, release, "migrate", 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if job.ID != "job-1" || job.Host != server.info.Name {
+		t.Errorf("expected job-1 on %s, got %s on %s", server.info.Name, job.ID, job.Host)
+	}
+
+	info := waitForJob(t, manager, job.ID)
+	if info.Status != JobExited || info.ExitCode == nil || *info.ExitCode != 3 {
+		t.Errorf("expected job to exit with 3, got %s (%v)", info.Status, info.ExitCode)
+	}
+	if info.OutputSize != 21 {
+		t.Errorf("expected 21 bytes of output, got %d", info.OutputSize)
+	}
+
+	// the output is read incrementally from the offset
+	output, err := manager.JobOutput(job.ID, 0, 7)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if output.Output != "line 1\n" || output.NextOffset != 7 {
+		t.Errorf("expected the first line up to offset 7, got %q up to %d", output.Output, output.NextOffset)
+	}
+	output, err = manager.JobOutput(job.ID, output.NextOffset, 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if output.Output != "line 2\nline 3\n" || output.NextOffset != 21 || output.Status != JobExited {
+		t.Errorf("expected the rest of the output, got %q up to %d (%s)", output.Output, output.NextOffset, output.Status)
+	}
+
+	// finished jobs are still listed
+	if jobs := manager.Jobs(); len(jobs) != 1 {
+		t.Errorf("expected 1 job, got %d", len(jobs))
+	}
+	_, err = manager.JobOutput("job-2", 0, 0)
+	if !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected job not found, got %v", err)
+	}
+}
+
+func TestManager_SignalJob(t *testing.T) {
+	server := newTestServer(t, blockingHandler(t))
+	manager := NewManager()
+	defer manager.Close()
+
+	client, release, err := manager.Get(context.Background(), server.info, nil, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	job, err := manager.StartJob(client, release, "hang", 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	_, err = manager.SignalJob(job.ID, "INT")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	waitForSignal(t, server, "INT")
+	if info, _ := manager.Job(job.ID); info.Status != JobRunning {
+		t.Errorf("expected job to still run after INT, got %s", info.Status)
+	}
+
+	_, err = manager.SignalJob(job.ID, "SIGSTOP")
+	if err == nil {
+		t.Error("expected an invalid signal to fail")
+	}
+
+	info, err := manager.SignalJob(job.ID, "KILL")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if info.Status != JobKilled || info.FinishedAt == nil {
+		t.Errorf("expected job to be killed, got %s", info.Status)
+	}
+	_, err = manager.SignalJob(job.ID, "TERM")
+	if err == nil {
+		t.Error("expected signalling a finished job to fail")
+	}
+}
+
+func TestManager_StartJobWithoutCommandTimeout(t *testing.T) {
+	server := newTestServer(t, blockingHandler(t))
+	manager := NewManager()
+	defer manager.Close()
+
+	info := server.info
+	info.CommandTimeout = "50ms"
+	client, release, err := manager.Get(context.Background(), info, nil, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	job, err := manager.StartJob(client, release, "hang", 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// the job keeps running past the command timeout of the host
+	time.Sleep(300 * time.Millisecond)
+	if info, _ := manager.Job(job.ID); info.Status != JobRunning {
+		t.Errorf("expected job to still run after the command timeout, got %s", info.Status)
+	}
+	_, err = manager.SignalJob(job.ID, "KILL")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestManager_PruneJobs(t *testing.T) {
+	server := newTestServer(t, blockingHandler(t))
+	manager := NewManager(WithJobRetention(50 * time.Millisecond))
+	defer manager.Close()
+
+	start := func(cmd string, outputSize int) (*JobInfo, error) {
+		client, release, err := manager.Get(context.Background(), server.info, nil, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return manager.StartJob(client, release, cmd, outputSize)
+	}
+	_, err := start("true", MaxJobOutputSize+1)
+	if err == nil {
+		t.Error("expected an output size over the maximum to fail")
+	}
+	running, err := start("hang", 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	finished, err := start("true", 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	waitForJob(t, manager, finished.ID)
+
+	// finished jobs are removed after the retention, running jobs are kept
+	time.Sleep(100 * time.Millisecond)
+	manager.pruneJobs()
+	_, err = manager.Job(finished.ID)
+	if !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected the finished job to be removed, got %v", err)
+	}
+	if info, err := manager.Job(running.ID); err != nil || info.Status != JobRunning {
+		t.Errorf("expected the running job to be kept, got %v", err)
+	}
+
+	// only the most recently finished jobs are kept
+	manager.mx.Lock()
+	now := time.Now()
+	manager.jobRetention = 0
+	for i := range maxFinishedJobs + 1 {
+		id := fmt.Sprintf("finished-%d", i)
+		manager.jobs[id] = &job{id: id, finishedAt: now.Add(time.Duration(i) * time.Second)}
+	}
+	manager.pruneJobsLocked(now)
+	_, oldest := manager.jobs["finished-0"]
+	_, newest := manager.jobs[fmt.Sprintf("finished-%d", maxFinishedJobs)]
+	count := len(manager.jobs)
+	for i := range maxFinishedJobs + 1 {
+		delete(manager.jobs, fmt.Sprintf("finished-%d", i))
+	}
+	manager.mx.Unlock()
+	if oldest || !newest || count != maxFinishedJobs+1 {
+		t.Errorf("expected the oldest finished job to be removed, got %d jobs", count)
+	}
+}
+
+func TestOutputBuffer(t *testing.T) {
+	b := newOutputBuffer(8)
+	for _, line := range []string{"one", "two", "three", "four"} {
+		b.WriteLine(line)
+	}
+	if size := b.Size(); size != 19 {
+		t.Errorf("expected 19 bytes written, got %d", size)
+	}
+
+	// only the last 8 bytes are kept
+	data, start, next := b.Read(0, 0)
+	if string(data) != "ee\nfour\n" || start != 11 || next != 19 {
+		t.Errorf("expected the last 8 bytes from 11, got %q from %d to %d", data, start, next)
+	}
+	data, start, next = b.Read(14, 3)
+	if string(data) != "fou" || start != 14 || next != 17 {
+		t.Errorf("expected 3 bytes from 14, got %q from %d to %d", data, start, next)
+	}
+	data, _, next = b.Read(100, 0)
+	if len(data) != 0 || next != 19 {
+		t.Errorf("expected no data past the end, got %q to %d", data, next)
+	}
+}
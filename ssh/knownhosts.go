@@ -0,0 +1,291 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// UnknownHostKeyError returned when the host key is not present in the known_hosts file.
+type UnknownHostKeyError struct {
+	Host        string
+	Fingerprint string
+}
+
+// Error returns the error message.
+func (e *UnknownHostKeyError) Error() string {
+	return fmt.Sprintf("host key for %s is not known (presented %s)", e.Host, e.Fingerprint)
+}
+
+// HostKeyMismatchError returned when the host key does not match the key in the known_hosts file.
+type HostKeyMismatchError struct {
+	Host        string
+	Fingerprint string
+	Known       []string
+}
+
+// Error returns the error message.
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf(
+		"HOST KEY MISMATCH for %s: presented %s but known_hosts has %s; this could be a man-in-the-middle attack",
+		e.Host, e.Fingerprint, strings.Join(e.Known, ", "))
+}
+
+// errHostKeyScanned is returned from the host key callback to stop the connection once the key is retrieved.
+var errHostKeyScanned = errors.New("host key scanned")
+
+// HostKey is the host key presented by a host.
+type HostKey struct {
+	Host        string `json:"host"`
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+
+	remote net.Addr
+	key    ssh.PublicKey
+}
+
+// KnownHosts verifies host keys against a known_hosts file.
+type KnownHosts struct {
+	path string
+
+	mx sync.Mutex
+}
+
+// NewKnownHosts creates a KnownHosts backed by the file at path.
+//
+// The file is created on first write if it doesn't exist. A leading "~/" is expanded to the home directory.
+func NewKnownHosts(path string) *KnownHosts {
+	return &KnownHosts{
+		path: expandHome(path),
+	}
+}
+
+// Path returns the path of the known_hosts file.
+func (k *KnownHosts) Path() string {
+	return k.path
+}
+
+// HostKeyCallback returns the callback that verifies host keys against the known_hosts file.
+//
+// When onUnknown is not nil a host that is not present in the file is accepted and its key is passed to
+// onUnknown instead of returning an UnknownHostKeyError, the key is only trusted once it is recorded with
+// Trust. A host with a different key always returns a HostKeyMismatchError.
+func (k *KnownHosts) HostKeyCallback(onUnknown func(hostKey *HostKey)) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := k.Check(hostname, remote, key)
+		var unknownErr *UnknownHostKeyError
+		if onUnknown != nil && errors.As(err, &unknownErr) {
+			onUnknown(&HostKey{
+				Host:        hostname,
+				Type:        key.Type(),
+				Fingerprint: ssh.FingerprintSHA256(key),
+				remote:      remote,
+				key:         key,
+			})
+			return nil
+		}
+		return err
+	}
+}
+
+// Trust records the host key when the host is not known yet, a host that is already known must have the same key.
+func (k *KnownHosts) Trust(hostKey *HostKey) error {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+
+	err := k.check(hostKey.Host, hostKey.remote, hostKey.key)
+	var unknownErr *UnknownHostKeyError
+	if errors.As(err, &unknownErr) {
+		return k.append(hostKey.Host, hostKey.key)
+	}
+	return err
+}
+
+// Check verifies the key for the host against the known_hosts file.
+func (k *KnownHosts) Check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	return k.check(hostname, remote, key)
+}
+
+// Verify verifies the scanned host key against the known_hosts file.
+func (k *KnownHosts) Verify(hostKey *HostKey) error {
+	return k.Check(hostKey.Host, hostKey.remote, hostKey.key)
+}
+
+// Accept replaces all the keys for the host with the scanned host key.
+func (k *KnownHosts) Accept(hostKey *HostKey) error {
+	return k.Replace(hostKey.Host, hostKey.key)
+}
+
+// Replace removes all the keys for the host and records the provided key.
+//
+// Only plain key lines are removed, @revoked and @cert-authority lines for the host are kept.
+func (k *KnownHosts) Replace(hostname string, key ssh.PublicKey) error {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+
+	data, err := k.read()
+	if err != nil {
+		return err
+	}
+	var kept [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		marker, hosts, _, _, _, err := ssh.ParseKnownHosts(line)
+		if err == nil && marker == "" && matchesHost(hosts, hostname) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	kept = append(kept, []byte(knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)))
+	return k.write(append(bytes.Join(kept, []byte("\n")), '\n'))
+}
+
+// HostKeyAlgorithms returns the host key algorithms for the keys known for the host.
+//
+// This ensures that the server presents the type of key that is already known, instead of
+// a different type that would be reported as a mismatch.
+func (k *KnownHosts) HostKeyAlgorithms(hostname string) []string {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+
+	data, err := k.read()
+	if err != nil {
+		return nil
+	}
+	var algos []string
+	seen := make(map[string]bool)
+	for len(data) > 0 {
+		var marker string
+		var hosts []string
+		var key ssh.PublicKey
+		marker, hosts, key, _, data, err = ssh.ParseKnownHosts(data)
+		if err != nil {
+			break
+		}
+		if marker != "" || !matchesHost(hosts, hostname) {
+			continue
+		}
+		for _, algo := range keyAlgorithms(key.Type()) {
+			if !seen[algo] {
+				seen[algo] = true
+				algos = append(algos, algo)
+			}
+		}
+	}
+	return algos
+}
+
+func (k *KnownHosts) check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	callback, err := knownhosts.New(k.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &UnknownHostKeyError{Host: hostname, Fingerprint: ssh.FingerprintSHA256(key)}
+		}
+		return fmt.Errorf("failed to load known_hosts: %w", err)
+	}
+	err = callback(hostname, remote, key)
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) {
+		if len(keyErr.Want) == 0 {
+			return &UnknownHostKeyError{Host: hostname, Fingerprint: ssh.FingerprintSHA256(key)}
+		}
+		known := make([]string, 0, len(keyErr.Want))
+		for _, want := range keyErr.Want {
+			known = append(known, ssh.FingerprintSHA256(want.Key))
+		}
+		return &HostKeyMismatchError{Host: hostname, Fingerprint: ssh.FingerprintSHA256(key), Known: known}
+	}
+	return err
+}
+
+func (k *KnownHosts) append(hostname string, key ssh.PublicKey) error {
+	data, err := k.read()
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	data = append(data, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)+"\n"...)
+	return k.write(data)
+}
+
+func (k *KnownHosts) read() ([]byte, error) {
+	data, err := os.ReadFile(k.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read known_hosts: %w", err)
+	}
+	return data, nil
+}
+
+func (k *KnownHosts) write(data []byte) error {
+	err := os.MkdirAll(filepath.Dir(k.path), 0700)
+	if err != nil {
+		return fmt.Errorf("failed to create known_hosts directory: %w", err)
+	}
+	err = os.WriteFile(k.path, data, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write known_hosts: %w", err)
+	}
+	return nil
+}
+
+// matchesHost returns true when one of the known_hosts patterns is exactly the host.
+//
+// Wildcard patterns are not matched, but hashed hostnames are.
+func matchesHost(patterns []string, hostname string) bool {
+	normalized := knownhosts.Normalize(hostname)
+	for _, pattern := range patterns {
+		if pattern == normalized {
+			return true
+		}
+		if strings.HasPrefix(pattern, "|1|") && matchesHashedHost(pattern, normalized) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesHashedHost returns true when the hashed pattern (|1|salt|hash) is the host.
+func matchesHashedHost(pattern string, hostname string) bool {
+	parts := strings.Split(pattern[len("|1|"):], "|")
+	if len(parts) != 2 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(hostname))
+	return hmac.Equal(mac.Sum(nil), hash)
+}
+
+// keyAlgorithms returns the host key algorithms that can be used to present a key of the type.
+func keyAlgorithms(keyType string) []string {
+	switch keyType {
+	case ssh.KeyAlgoRSA:
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	default:
+		return []string{keyType}
+	}
+}
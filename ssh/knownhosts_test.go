@@ -0,0 +1,227 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("failed to create public key: %v", err)
+	}
+	return key
+}
+
+var testRemote = &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 22}
+
+func TestKnownHosts_UnknownHost(t *testing.T) {
+	k := NewKnownHosts(filepath.Join(t.TempDir(), "known_hosts"))
+	err := k.HostKeyCallback(nil)("host:22", testRemote, newHostKey(t))
+	var unknownErr *UnknownHostKeyError
+	if !errors.As(err, &unknownErr) {
+		t.Errorf("expected UnknownHostKeyError, got %v", err)
+	}
+}
+
+// trust accepts the unknown host key and records it.
+func trust(t *testing.T, k *KnownHosts, hostname string, key ssh.PublicKey) {
+	t.Helper()
+	var pending *HostKey
+	err := k.HostKeyCallback(func(hostKey *HostKey) {
+		pending = hostKey
+	})(hostname, testRemote, key)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if pending == nil {
+		t.Fatalf("expected the key of %s to be pending", hostname)
+	}
+	err = k.Trust(pending)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestKnownHosts_TrustOnFirstUse(t *testing.T) {
+	k := NewKnownHosts(filepath.Join(t.TempDir(), "known_hosts"))
+	key := newHostKey(t)
+	var pending *HostKey
+	err := k.HostKeyCallback(func(hostKey *HostKey) {
+		pending = hostKey
+	})("host:22", testRemote, key)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// the pending key is not trusted until it is recorded
+	var unknownErr *UnknownHostKeyError
+	if err := k.Check("host:22", testRemote, key); !errors.As(err, &unknownErr) {
+		t.Errorf("expected pending key to not be trusted, got %v", err)
+	}
+	err = k.Trust(pending)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	err = k.HostKeyCallback(nil)("host:22", testRemote, key)
+	if err != nil {
+		t.Errorf("expected recorded key to be trusted, got %v", err)
+	}
+}
+
+func TestKnownHosts_Mismatch(t *testing.T) {
+	k := NewKnownHosts(filepath.Join(t.TempDir(), "known_hosts"))
+	key := newHostKey(t)
+	trust(t, k, "host:2222", key)
+
+	// trust on first use must never replace a known key
+	err := k.HostKeyCallback(func(hostKey *HostKey) {
+		t.Error("expected a known host to never be pending")
+	})("host:2222", testRemote, newHostKey(t))
+	var mismatchErr *HostKeyMismatchError
+	if !errors.As(err, &mismatchErr) {
+		t.Fatalf("expected HostKeyMismatchError, got %v", err)
+	}
+	if len(mismatchErr.Known) != 1 || mismatchErr.Known[0] != ssh.FingerprintSHA256(key) {
+		t.Errorf("expected known fingerprint %s, got %v", ssh.FingerprintSHA256(key), mismatchErr.Known)
+	}
+}
+
+func TestKnownHosts_Replace(t *testing.T) {
+	k := NewKnownHosts(filepath.Join(t.TempDir(), "known_hosts"))
+	other := newHostKey(t)
+	trust(t, k, "other:22", other)
+	trust(t, k, "host:22", newHostKey(t))
+	revoked := newHostKey(t)
+	data, err := os.ReadFile(k.Path())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	data = append(data, "@revoked "+knownhosts.Line([]string{knownhosts.Normalize("host:22")}, revoked)+"\n"...)
+	err = os.WriteFile(k.Path(), data, 0600)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	key := newHostKey(t)
+	err = k.Replace("host:22", key)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := k.Check("host:22", testRemote, key); err != nil {
+		t.Errorf("expected replaced key to be trusted, got %v", err)
+	}
+	if err := k.Check("other:22", testRemote, other); err != nil {
+		t.Errorf("expected other host to be untouched, got %v", err)
+	}
+	var revokedErr *knownhosts.RevokedError
+	if err := k.Check("host:22", testRemote, revoked); !errors.As(err, &revokedErr) {
+		t.Errorf("expected the revocation to be kept, got %v", err)
+	}
+}
+
+func TestKnownHosts_HostKeyAlgorithms(t *testing.T) {
+	k := NewKnownHosts(filepath.Join(t.TempDir(), "known_hosts"))
+	if algos := k.HostKeyAlgorithms("host:22"); len(algos) != 0 {
+		t.Errorf("expected no algorithms for unknown host, got %v", algos)
+	}
+	if err := k.Replace("host:22", newHostKey(t)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	algos := k.HostKeyAlgorithms("host:22")
+	if len(algos) != 1 || algos[0] != ssh.KeyAlgoED25519 {
+		t.Errorf("expected [%s], got %v", ssh.KeyAlgoED25519, algos)
+	}
+}
+
+func TestMatchesHost_Hashed(t *testing.T) {
+	hashed := knownhosts.HashHostname(knownhosts.Normalize("host:2222"))
+	if !matchesHost([]string{hashed}, "host:2222") {
+		t.Errorf("expected hashed hostname to match")
+	}
+	if matchesHost([]string{hashed}, "host:22") {
+		t.Errorf("expected hashed hostname not to match a different port")
+	}
+}
+
+func TestClient_TrustOnFirstUseAfterAuthentication(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	k := NewKnownHosts(filepath.Join(t.TempDir(), "known_hosts"))
+
+	// a failed login never trusts the host key
+	info := server.info
+	info.Pass = "wrong"
+	client := NewClient(&info, WithKnownHosts(k, true))
+	err := client.Connect(context.Background())
+	if err == nil {
+		_ = client.Close()
+		t.Fatal("expected the wrong password to fail")
+	}
+	var unknownErr *UnknownHostKeyError
+	if err := k.Check(client.address(), testRemote, server.hostKey); !errors.As(err, &unknownErr) {
+		t.Fatalf("expected the host key to not be recorded, got %v", err)
+	}
+
+	client = NewClient(&server.info, WithKnownHosts(k, true))
+	err = client.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer client.Close()
+	if err := k.Check(client.address(), testRemote, server.hostKey); err != nil {
+		t.Errorf("expected the host key to be recorded, got %v", err)
+	}
+}
+
+func TestClient_ScanHostKeyKnownType(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	rsaSigner, err := ssh.NewSignerFromKey(rsaKey)
+	if err != nil {
+		t.Fatalf("failed to create host key signer: %v", err)
+	}
+	// the server presents both an ed25519 and an RSA host key
+	server := newTestServer(t, echoHandler, func(cfg *ssh.ServerConfig) {
+		cfg.AddHostKey(rsaSigner)
+	})
+
+	for _, known := range []ssh.PublicKey{server.hostKey, rsaSigner.PublicKey()} {
+		t.Run(known.Type(), func(t *testing.T) {
+			k := NewKnownHosts(filepath.Join(t.TempDir(), "known_hosts"))
+			client := NewClient(&server.info, WithKnownHosts(k, false))
+			err := k.Replace(client.address(), known)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			// the key of the known type is presented, so it is not reported as changed
+			hostKey, err := client.ScanHostKey(context.Background())
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if hostKey.Type != known.Type() {
+				t.Errorf("expected the %s host key, got %s", known.Type(), hostKey.Type)
+			}
+			err = k.Verify(hostKey)
+			if err != nil {
+				t.Errorf("expected the scanned host key to be trusted, got %v", err)
+			}
+		})
+	}
+}
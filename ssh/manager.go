@@ -0,0 +1,474 @@
+package ssh
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrManagerClosed returned when the manager is already closed.
+var ErrManagerClosed = errors.New("connection manager closed")
+
+// ErrConnectionLimit returned when no connection can be made because the maximum number of connections is open.
+var ErrConnectionLimit = errors.New("connection limit reached")
+
+const (
+	// DefaultIdleTimeout is the default time an unused connection is kept open.
+	DefaultIdleTimeout = 5 * time.Minute
+	// DefaultKeepAlive is the default interval between keepalives on open connections.
+	DefaultKeepAlive = 30 * time.Second
+	// DefaultMaxConnections is the default maximum number of open connections.
+	DefaultMaxConnections = 64
+	// DefaultConnectionWait is the default time to wait for a connection to be released when at the maximum.
+	DefaultConnectionWait = time.Minute
+)
+
+// Manager keeps the connections to the SSH servers open so they are reused between tool calls.
+//
+// Connections that are unused for the idle timeout are closed, open connections are kept alive
+// with keepalives and broken connections are transparently reconnected. Connections to jump hosts
+// are managed the same way and count towards the maximum number of open connections.
+type Manager struct {
+	idleTimeout    time.Duration
+	keepAlive      time.Duration
+	maxConnections int
+	connectionWait time.Duration
+	proxy          string
+	jobRetention   time.Duration
+
+	mx      sync.Mutex
+	conns   map[string]*managedConn
+	changed chan struct{}
+	closed  bool
+
+	tunnels  map[string]*tunnel
+	tunnelID int
+	jobs     map[string]*job
+	jobID    int
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+type managedConn struct {
+	key    string
+	client *Client
+	err    error
+
+	// closed once connecting has finished
+	ready chan struct{}
+	// releases the connection to the jump host
+	releaseJump func()
+
+	refs     int
+	lastUsed time.Time
+	closed   bool
+}
+
+// ManagerOption is an option for the manager.
+type ManagerOption func(*Manager)
+
+// WithIdleTimeout sets the time an unused connection is kept open.
+func WithIdleTimeout(timeout time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.idleTimeout = timeout
+	}
+}
+
+// WithKeepAlive sets the interval between keepalives on open connections (zero disables keepalives).
+func WithKeepAlive(interval time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.keepAlive = interval
+	}
+}
+
+// WithMaxConnections sets the maximum number of open connections.
+func WithMaxConnections(max int) ManagerOption {
+	return func(m *Manager) {
+		m.maxConnections = max
+	}
+}
+
+// WithConnectionWait sets the time to wait for a connection to be released when at the maximum number
+// of connections, before failing with ErrConnectionLimit (zero waits until the context is done).
+func WithConnectionWait(wait time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.connectionWait = wait
+	}
+}
+
+// WithJobRetention sets the time a finished job and its output are kept.
+//
+// With zero finished jobs are only removed once there are too many of them.
+func WithJobRetention(retention time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.jobRetention = retention
+	}
+}
+
+// WithDefaultProxy sets the proxy that connections are made through unless the host sets its own proxy.
+func WithDefaultProxy(proxyURL string) ManagerOption {
+	return func(m *Manager) {
+		m.proxy = proxyURL
+	}
+}
+
+// NewManager creates a new connection manager.
+func NewManager(opts ...ManagerOption) *Manager {
+	m := &Manager{
+		idleTimeout:    DefaultIdleTimeout,
+		keepAlive:      DefaultKeepAlive,
+		maxConnections: DefaultMaxConnections,
+		connectionWait: DefaultConnectionWait,
+		jobRetention:   DefaultJobRetention,
+		conns:          make(map[string]*managedConn),
+		tunnels:        make(map[string]*tunnel),
+		jobs:           make(map[string]*job),
+		changed:        make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.maxConnections <= 0 {
+		m.maxConnections = DefaultMaxConnections
+	}
+	m.wg.Add(1)
+	go m.maintain()
+	return m
+}
+
+// Get returns the connected client for the host that connects through the chain of jump hosts.
+//
+// An open connection is reused when possible, the options are only used when a new connection
+// is made. The returned release function must be called once the client is no longer used. The
+// context bounds both connecting and waiting for a free connection when at the maximum, which is
+// also bounded by the connection wait of the manager as the connections to the jump hosts are held
+// while waiting.
+func (m *Manager) Get(ctx context.Context, info ClientInfo, chain []ClientInfo, opts ...ClientOption) (*Client, func(), error) {
+	if len(chain)+1 > m.maxConnections {
+		return nil, nil, fmt.Errorf("%w: connecting through %d jump hosts needs %d connections, at most %d can be open", ErrConnectionLimit, len(chain), len(chain)+1, m.maxConnections)
+	}
+	var jump *Client
+	releaseJump := func() {}
+	if len(chain) > 0 {
+		last := chain[len(chain)-1]
+		var err error
+		jump, releaseJump, err = m.Get(ctx, last, chain[:len(chain)-1], opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to jump host %s: %w", last.Name, err)
+		}
+	}
+
+	key := connKey(append(slices.Clone(chain), info))
+	var waited <-chan time.Time
+	for {
+		m.mx.Lock()
+		if m.closed {
+			m.mx.Unlock()
+			releaseJump()
+			return nil, nil, ErrManagerClosed
+		}
+
+		conn, ok := m.conns[key]
+		if ok {
+			conn.refs++
+			idle := conn.refs == 1
+			m.mx.Unlock()
+
+			select {
+			case <-conn.ready:
+			case <-ctx.Done():
+				m.release(conn)
+				releaseJump()
+				return nil, nil, ctx.Err()
+			}
+			if conn.err != nil {
+				m.release(conn)
+				releaseJump()
+				return nil, nil, conn.err
+			}
+			// only check that an idle connection is still alive, a connection in use is kept alive by its users
+			if !idle || conn.client.alive() {
+				// the connection already holds the connection to its jump host
+				releaseJump()
+				return conn.client, m.releaseFunc(conn), nil
+			}
+			m.discard(conn)
+			m.release(conn)
+			continue
+		}
+
+		if len(m.conns) >= m.maxConnections {
+			evicted := m.evictIdleLocked()
+			if evicted == nil {
+				// wait for a connection to be released or closed
+				changed := m.changed
+				m.mx.Unlock()
+				if waited == nil && m.connectionWait > 0 {
+					timer := time.NewTimer(m.connectionWait)
+					defer timer.Stop()
+					waited = timer.C
+				}
+				select {
+				case <-changed:
+				case <-waited:
+					releaseJump()
+					return nil, nil, fmt.Errorf("%w: no connection was released within %s, at most %d can be open", ErrConnectionLimit, m.connectionWait, m.maxConnections)
+				case <-ctx.Done():
+					releaseJump()
+					return nil, nil, ctx.Err()
+				}
+				continue
+			}
+			m.mx.Unlock()
+			m.closeConn(evicted)
+			continue
+		}
+
+		conn = &managedConn{
+			key:         key,
+			ready:       make(chan struct{}),
+			releaseJump: releaseJump,
+			refs:        1,
+		}
+		m.conns[key] = conn
+		m.mx.Unlock()
+
+		clientOpts := append(m.ClientOptions(), opts...)
+		client := NewClient(&info, append(clientOpts, WithJump(jump))...)
+		err := client.Connect(ctx)
+
+		m.mx.Lock()
+		if err != nil {
+			conn.err = err
+			delete(m.conns, key)
+			m.notifyLocked()
+		} else {
+			conn.client = client
+			conn.lastUsed = time.Now()
+		}
+		close(conn.ready)
+		m.mx.Unlock()
+
+		if err != nil {
+			m.release(conn)
+			releaseJump()
+			return nil, nil, err
+		}
+		return client, m.releaseFunc(conn), nil
+	}
+}
+
+// ClientOptions returns the options that the manager applies to every client it creates.
+//
+// Clients that are created outside the manager should use them so they connect the same way.
+func (m *Manager) ClientOptions() []ClientOption {
+	var opts []ClientOption
+	if m.proxy != "" {
+		opts = append(opts, WithProxy(m.proxy))
+	}
+	return opts
+}
+
+// Len returns the number of open connections.
+func (m *Manager) Len() int {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return len(m.conns)
+}
+
+// Close kills all the jobs and closes all the tunnels and connections.
+func (m *Manager) Close() error {
+	m.mx.Lock()
+	if m.closed {
+		m.mx.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.mx.Unlock()
+
+	// the jobs and tunnels hold connections, so they are closed first
+	m.killJobs()
+	m.closeTunnels()
+
+	m.mx.Lock()
+	close(m.done)
+	conns := make([]*managedConn, 0, len(m.conns))
+	for key, conn := range m.conns {
+		conns = append(conns, conn)
+		delete(m.conns, key)
+	}
+	m.notifyLocked()
+	m.mx.Unlock()
+	m.wg.Wait()
+
+	// close the longest chains first as they are tunneled through the shorter ones
+	slices.SortFunc(conns, func(a, b *managedConn) int {
+		return strings.Count(b.key, connSeparator) - strings.Count(a.key, connSeparator)
+	})
+	for _, conn := range conns {
+		<-conn.ready
+		m.closeConn(conn)
+	}
+	return nil
+}
+
+// releaseFunc returns the function that releases the connection (only once).
+func (m *Manager) releaseFunc(conn *managedConn) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.release(conn)
+		})
+	}
+}
+
+// release releases a reference to the connection, closing it when it was removed and is no longer used.
+func (m *Manager) release(conn *managedConn) {
+	m.mx.Lock()
+	conn.refs--
+	conn.lastUsed = time.Now()
+	removed := m.conns[conn.key] != conn
+	unused := conn.refs == 0
+	m.notifyLocked()
+	m.mx.Unlock()
+
+	if removed && unused {
+		m.closeConn(conn)
+	}
+}
+
+// discard removes the broken connection so a new connection is made.
+func (m *Manager) discard(conn *managedConn) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.conns[conn.key] == conn {
+		delete(m.conns, conn.key)
+		m.notifyLocked()
+	}
+}
+
+// closeConn closes the connection and releases its jump host (only once).
+func (m *Manager) closeConn(conn *managedConn) {
+	m.mx.Lock()
+	if conn.closed || conn.client == nil {
+		m.mx.Unlock()
+		return
+	}
+	conn.closed = true
+	m.mx.Unlock()
+
+	_ = conn.client.Close()
+	conn.releaseJump()
+}
+
+// evictIdleLocked removes the least recently used connection that is not in use (m.mx must be held).
+func (m *Manager) evictIdleLocked() *managedConn {
+	var oldest *managedConn
+	for _, conn := range m.conns {
+		if conn.refs > 0 || conn.client == nil {
+			continue
+		}
+		if oldest == nil || conn.lastUsed.Before(oldest.lastUsed) {
+			oldest = conn
+		}
+	}
+	if oldest != nil {
+		delete(m.conns, oldest.key)
+	}
+	return oldest
+}
+
+// notifyLocked wakes up everything waiting for a connection to be released (m.mx must be held).
+func (m *Manager) notifyLocked() {
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+// maintain closes idle connections and sends keepalives on open connections until the manager is closed.
+func (m *Manager) maintain() {
+	defer m.wg.Done()
+
+	interval := m.keepAlive
+	if interval <= 0 || (m.idleTimeout > 0 && m.idleTimeout/2 < interval) {
+		interval = m.idleTimeout / 2
+	}
+	if interval <= 0 {
+		interval = DefaultKeepAlive
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+		m.pruneJobs()
+
+		var idle, open []*managedConn
+		m.mx.Lock()
+		now := time.Now()
+		for key, conn := range m.conns {
+			if conn.client == nil {
+				// still connecting
+				continue
+			}
+			if conn.refs == 0 && m.idleTimeout > 0 && now.Sub(conn.lastUsed) >= m.idleTimeout {
+				delete(m.conns, key)
+				idle = append(idle, conn)
+				continue
+			}
+			open = append(open, conn)
+		}
+		if len(idle) > 0 {
+			m.notifyLocked()
+		}
+		m.mx.Unlock()
+
+		for _, conn := range idle {
+			m.closeConn(conn)
+		}
+		if m.keepAlive <= 0 {
+			continue
+		}
+		for _, conn := range open {
+			if !conn.client.alive() {
+				m.discard(conn)
+				// the tunnels hold the connection open, so they are failed for it to be closed
+				m.failTunnels(conn.client, errors.New("no response to keepalive"))
+				m.mx.Lock()
+				unused := conn.refs == 0
+				m.mx.Unlock()
+				if unused {
+					m.closeConn(conn)
+				}
+			}
+		}
+	}
+}
+
+const connSeparator = ">"
+
+// connKey returns the key that identifies the connection to the last host in the chain.
+//
+// The key changes when any of the client information changes (except the OS information and the
+// negotiated algorithms, which are only recorded), so a connection is never reused after the
+// credentials of the host change.
+func connKey(chain []ClientInfo) string {
+	hops := make([]string, 0, len(chain))
+	for _, info := range chain {
+		info.OS = OSInfo{}
+		info.Algorithms = nil
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%#v", info)))
+		hops = append(hops, fmt.Sprintf("%s=%s@%s:%s#%x", info.Name, info.User, info.Host, info.Port, sum[:8]))
+	}
+	return strings.Join(hops, connSeparator)
+}
@@ -0,0 +1,242 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func insecureOption() ClientOption {
+	return WithHostKeyCallback(ssh.InsecureIgnoreHostKey())
+}
+
+func TestManager_GetReusesConnection(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	m := NewManager()
+	defer m.Close()
+
+	client, release, err := m.Get(context.Background(), server.info, nil, insecureOption())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	release()
+	again, release, err := m.Get(context.Background(), server.info, nil, insecureOption())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer release()
+
+	if client != again {
+		t.Errorf("expected the connection to be reused")
+	}
+	if server.Connections() != 1 {
+		t.Errorf("expected 1 connection, got %d", server.Connections())
+	}
+}
+
+func TestManager_GetReconnectsBrokenConnection(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	m := NewManager()
+	defer m.Close()
+
+	_, release, err := m.Get(context.Background(), server.info, nil, insecureOption())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	release()
+	server.DropConnections()
+
+	client, release, err := m.Get(context.Background(), server.info, nil, insecureOption())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer release()
+	result, err := client.Exec(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Stdout != "hello" {
+		t.Errorf("expected output 'hello', got '%s'", result.Stdout)
+	}
+	if server.Connections() != 2 {
+		t.Errorf("expected 2 connections, got %d", server.Connections())
+	}
+}
+
+func TestManager_IdleTimeout(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	m := NewManager(WithIdleTimeout(50*time.Millisecond), WithKeepAlive(0))
+	defer m.Close()
+
+	_, release, err := m.Get(context.Background(), server.info, nil, insecureOption())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	release()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for m.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if m.Len() != 0 {
+		t.Errorf("expected idle connection to be closed, got %d open", m.Len())
+	}
+}
+
+func TestManager_MaxConnections(t *testing.T) {
+	first := newTestServer(t, echoHandler)
+	second := newTestServer(t, echoHandler)
+	m := NewManager(WithMaxConnections(1))
+	defer m.Close()
+
+	_, release, err := m.Get(context.Background(), first.info, nil, insecureOption())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	got := make(chan error, 1)
+	go func() {
+		_, releaseSecond, err := m.Get(context.Background(), second.info, nil, insecureOption())
+		if err == nil {
+			releaseSecond()
+		}
+		got <- err
+	}()
+
+	select {
+	case <-got:
+		t.Fatalf("expected to wait for a connection to be released")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// releasing allows the idle connection to be evicted
+	release()
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected connection after release")
+	}
+	if m.Len() != 1 {
+		t.Errorf("expected 1 open connection, got %d", m.Len())
+	}
+}
+
+func TestManager_ConnectionLimit(t *testing.T) {
+	bastion := newTestServer(t, echoHandler)
+	first := newTestServer(t, echoHandler)
+	second := newTestServer(t, echoHandler)
+	m := NewManager(WithMaxConnections(2), WithConnectionWait(100*time.Millisecond))
+	defer m.Close()
+
+	// the chain cannot be connected even when no connection is open
+	_, _, err := m.Get(context.Background(), second.info, []ClientInfo{bastion.info, first.info}, insecureOption())
+	if !errors.Is(err, ErrConnectionLimit) {
+		t.Fatalf("expected the connection limit for the chain, got %v", err)
+	}
+
+	_, release, err := m.Get(context.Background(), first.info, nil, insecureOption())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer release()
+
+	// the jump host takes the last connection, the wait for the target is bounded
+	_, _, err = m.Get(context.Background(), second.info, []ClientInfo{bastion.info}, insecureOption())
+	if !errors.Is(err, ErrConnectionLimit) {
+		t.Fatalf("expected the connection limit, got %v", err)
+	}
+	if m.Len() != 2 {
+		t.Errorf("expected 2 open connections, got %d", m.Len())
+	}
+}
+
+func TestManager_GetThroughJumpHost(t *testing.T) {
+	bastion := newTestServer(t, echoHandler)
+	first := newTestServer(t, echoHandler)
+	second := newTestServer(t, echoHandler)
+	m := NewManager()
+	defer m.Close()
+
+	for _, target := range []*testServer{first, second} {
+		client, release, err := m.Get(context.Background(), target.info, []ClientInfo{bastion.info}, insecureOption())
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		result, err := client.Exec(context.Background(), "hello")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if result.Stdout != "hello" {
+			t.Errorf("expected output 'hello', got '%s'", result.Stdout)
+		}
+		release()
+	}
+
+	if bastion.Connections() != 1 {
+		t.Errorf("expected the jump host connection to be shared, got %d connections", bastion.Connections())
+	}
+	if m.Len() != 3 {
+		t.Errorf("expected 3 open connections, got %d", m.Len())
+	}
+}
+
+func TestManager_GetError(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	info := server.info
+	info.Pass = "wrong"
+	m := NewManager()
+	defer m.Close()
+
+	_, _, err := m.Get(context.Background(), info, nil, insecureOption())
+	if err == nil {
+		t.Fatalf("expected error for failed authentication, got nil")
+	}
+	if m.Len() != 0 {
+		t.Errorf("expected no open connections, got %d", m.Len())
+	}
+}
+
+func TestManager_Close(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	m := NewManager()
+
+	_, release, err := m.Get(context.Background(), server.info, nil, insecureOption())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	release()
+	err = m.Close()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	_, _, err = m.Get(context.Background(), server.info, nil, insecureOption())
+	if err != ErrManagerClosed {
+		t.Errorf("expected ErrManagerClosed, got %v", err)
+	}
+}
+
+func TestConnKey_IgnoresRecordedInfo(t *testing.T) {
+	info := ClientInfo{Name: "web1", Host: "10.0.0.1", Port: "22", User: "root", Pass: "secret"}
+	key := connKey([]ClientInfo{info})
+
+	// the OS information and negotiated algorithms are only recorded after connecting
+	recorded := info
+	recorded.OS = OSInfo{Name: "Ubuntu", Platform: "ubuntu", Version: "24.04", Arch: "x86_64"}
+	recorded.Algorithms = &NegotiatedAlgorithms{KeyExchange: "curve25519-sha256", HostKey: "ssh-ed25519", Cipher: "aes128-ctr"}
+	if connKey([]ClientInfo{recorded}) != key {
+		t.Error("expected the recorded information to not change the connection key")
+	}
+
+	changed := info
+	changed.Pass = "changed"
+	if connKey([]ClientInfo{changed}) == key {
+		t.Error("expected the changed password to change the connection key")
+	}
+}
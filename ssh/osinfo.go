@@ -0,0 +1,169 @@
+package ssh
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnrecognizedOS returned when the output does not identify the operating system.
+var ErrUnrecognizedOS = errors.New("unrecognized operating system")
+
+// ParseOSRelease returns the OS information from the content of /etc/os-release and the output of uname -srm.
+//
+// The platform is the ID of the distribution (e.g. ubuntu), rolling distributions without a
+// VERSION_ID use their BUILD_ID as the version.
+func ParseOSRelease(osRelease string, uname string) (*OSInfo, error) {
+	fields := parseOSReleaseFields(osRelease)
+	info := &OSInfo{
+		// defaults from os-release(5)
+		Name:     "Linux",
+		Platform: "linux",
+		Version:  fields["VERSION_ID"],
+		Arch:     unameMachine(uname),
+	}
+	if name := fields["NAME"]; name != "" {
+		info.Name = name
+	}
+	if id := fields["ID"]; id != "" {
+		info.Platform = id
+	}
+	if info.Version == "" {
+		info.Version = fields["BUILD_ID"]
+	}
+	return validOSInfo(info)
+}
+
+// ParseSwVers returns the OS information of macOS from the output of sw_vers and uname -srm.
+func ParseSwVers(swVers string, uname string) (*OSInfo, error) {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(swVers))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok {
+			fields[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	info := &OSInfo{
+		Name:     fields["ProductName"],
+		Platform: "darwin",
+		Version:  fields["ProductVersion"],
+		Arch:     unameMachine(uname),
+	}
+	return validOSInfo(info)
+}
+
+// ParseFreeBSDVersion returns the OS information of FreeBSD from the output of freebsd-version and uname -srm.
+func ParseFreeBSDVersion(version string, uname string) (*OSInfo, error) {
+	info := &OSInfo{
+		Name:     "FreeBSD",
+		Platform: "freebsd",
+		Version:  strings.TrimSpace(version),
+		Arch:     unameMachine(uname),
+	}
+	return validOSInfo(info)
+}
+
+// ParseUname returns the OS information from the output of uname -srm (e.g. "OpenBSD 7.4 amd64").
+func ParseUname(uname string) (*OSInfo, error) {
+	fields := strings.Fields(uname)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("%w: expected the name, release and machine from uname, got %q", ErrUnrecognizedOS, strings.TrimSpace(uname))
+	}
+	return &OSInfo{
+		Name:     fields[0],
+		Platform: strings.ToLower(fields[0]),
+		Version:  fields[1],
+		Arch:     fields[2],
+	}, nil
+}
+
+// ParseWindowsOS returns the OS information of Windows from the Win32_OperatingSystem instance as JSON.
+func ParseWindowsOS(data string) (*OSInfo, error) {
+	var windows struct {
+		Caption        string
+		Version        string
+		OSArchitecture string
+	}
+	err := json.Unmarshal([]byte(data), &windows)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnrecognizedOS, err)
+	}
+	info := &OSInfo{
+		Name:     strings.TrimSpace(windows.Caption),
+		Platform: "windows",
+		Version:  strings.TrimSpace(windows.Version),
+		Arch:     windowsArch(windows.OSArchitecture),
+	}
+	return validOSInfo(info)
+}
+
+// parseOSReleaseFields returns the variables of os-release, with the quotes and escapes of the values removed.
+func parseOSReleaseFields(osRelease string) map[string]string {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(osRelease))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		var unescaped strings.Builder
+		for i := 0; i < len(value); i++ {
+			if value[i] == '\\' && i+1 < len(value) {
+				i++
+			}
+			unescaped.WriteByte(value[i])
+		}
+		fields[key] = unescaped.String()
+	}
+	return fields
+}
+
+// unameMachine returns the machine from the output of uname -srm, empty when it was not run.
+func unameMachine(uname string) string {
+	fields := strings.Fields(uname)
+	if len(fields) != 3 {
+		return ""
+	}
+	return fields[2]
+}
+
+// windowsArch returns the architecture from the OSArchitecture of Windows (e.g. "64-bit").
+func windowsArch(arch string) string {
+	switch {
+	case strings.Contains(arch, "ARM") && strings.Contains(arch, "64"):
+		return "arm64"
+	case strings.Contains(arch, "64"):
+		return "x86_64"
+	case strings.Contains(arch, "32"):
+		return "x86"
+	}
+	return strings.TrimSpace(arch)
+}
+
+// validOSInfo returns the OS information, or an error when it is incomplete.
+func validOSInfo(info *OSInfo) (*OSInfo, error) {
+	var missing []string
+	if info.Name == "" {
+		missing = append(missing, "name")
+	}
+	if info.Version == "" {
+		missing = append(missing, "version")
+	}
+	if info.Arch == "" {
+		missing = append(missing, "arch")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: missing %s", ErrUnrecognizedOS, strings.Join(missing, ", "))
+	}
+	return info, nil
+}
@@ -0,0 +1,130 @@
+package ssh
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseOSInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		parse    func() (*OSInfo, error)
+		expected OSInfo
+	}{
+		{
+			name: "ubuntu",
+			parse: func() (*OSInfo, error) {
+				return ParseOSRelease(`PRETTY_NAME="Ubuntu 22.04.4 LTS"
+NAME="Ubuntu"
+VERSION_ID="22.04"
+VERSION="22.04.4 LTS (Jammy Jellyfish)"
+ID=ubuntu
+ID_LIKE=debian
+`, "Linux 5.15.0-105-generic x86_64\n")
+			},
+			expected: OSInfo{Name: "Ubuntu", Platform: "ubuntu", Version: "22.04", Arch: "x86_64"},
+		},
+		{
+			name: "arch linux",
+			parse: func() (*OSInfo, error) {
+				return ParseOSRelease(`# rolling release
+NAME='Arch Linux'
+ID=arch
+BUILD_ID=rolling
+`, "Linux 6.8.9-arch1-1 aarch64")
+			},
+			expected: OSInfo{Name: "Arch Linux", Platform: "arch", Version: "rolling", Arch: "aarch64"},
+		},
+		{
+			name: "os-release defaults",
+			parse: func() (*OSInfo, error) {
+				return ParseOSRelease(`VERSION_ID="1.0 \"beta\""`, "Linux 6.1.0 riscv64")
+			},
+			expected: OSInfo{Name: "Linux", Platform: "linux", Version: `1.0 "beta"`, Arch: "riscv64"},
+		},
+		{
+			name: "macos",
+			parse: func() (*OSInfo, error) {
+				return ParseSwVers("ProductName:\t\tmacOS\nProductVersion:\t\t14.4.1\nBuildVersion:\t\t23E224\n", "Darwin 23.4.0 arm64\n")
+			},
+			expected: OSInfo{Name: "macOS", Platform: "darwin", Version: "14.4.1", Arch: "arm64"},
+		},
+		{
+			name: "freebsd",
+			parse: func() (*OSInfo, error) {
+				return ParseFreeBSDVersion("14.0-RELEASE-p6\n", "FreeBSD 14.0-RELEASE-p6 amd64\n")
+			},
+			expected: OSInfo{Name: "FreeBSD", Platform: "freebsd", Version: "14.0-RELEASE-p6", Arch: "amd64"},
+		},
+		{
+			name: "openbsd",
+			parse: func() (*OSInfo, error) {
+				return ParseUname("OpenBSD 7.5 amd64\n")
+			},
+			expected: OSInfo{Name: "OpenBSD", Platform: "openbsd", Version: "7.5", Arch: "amd64"},
+		},
+		{
+			name: "windows",
+			parse: func() (*OSInfo, error) {
+				return ParseWindowsOS(`{
+    "Caption":  "Microsoft Windows Server 2022 Datacenter",
+    "Version":  "10.0.20348",
+    "BuildNumber":  "20348",
+    "OSArchitecture":  "64-bit"
+}`)
+			},
+			expected: OSInfo{Name: "Microsoft Windows Server 2022 Datacenter", Platform: "windows", Version: "10.0.20348", Arch: "x86_64"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := tt.parse()
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if *info != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, *info)
+			}
+		})
+	}
+}
+
+func TestParseOSInfo_Unrecognized(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func() (*OSInfo, error)
+	}{
+		{
+			name: "os-release without version",
+			parse: func() (*OSInfo, error) {
+				return ParseOSRelease("NAME=Custom\nID=custom\n", "Linux 6.1.0 x86_64")
+			},
+		},
+		{
+			name: "os-release without uname",
+			parse: func() (*OSInfo, error) {
+				return ParseOSRelease("NAME=Debian\nVERSION_ID=12\n", "")
+			},
+		},
+		{
+			name: "uname -a",
+			parse: func() (*OSInfo, error) {
+				return ParseUname("SunOS host 5.11 11.4.0.15.0 i86pc i386 i86pc")
+			},
+		},
+		{
+			name: "windows ver",
+			parse: func() (*OSInfo, error) {
+				return ParseWindowsOS("Microsoft Windows [Version 10.0.20348.2402]")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.parse()
+			if !errors.Is(err, ErrUnrecognizedOS) {
+				t.Errorf("expected unrecognized operating system, got %v", err)
+			}
+		})
+	}
+}
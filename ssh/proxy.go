@@ -0,0 +1,140 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/proxy"
+)
+
+// NoProxy is the proxy of a host that connects directly even when a default proxy is set.
+const NoProxy = "none"
+
+// ParseProxy parses the proxy URL, either socks5://[user:pass@]host:port or http://[user:pass@]host:port.
+//
+// An empty proxy (or NoProxy) returns nil, which means a direct connection.
+func ParseProxy(proxyURL string) (*url.URL, error) {
+	if proxyURL == "" || proxyURL == NoProxy {
+		return nil, nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h", "http":
+	default:
+		return nil, fmt.Errorf("invalid proxy %s: scheme must be socks5 or http", u.Redacted())
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return nil, fmt.Errorf("invalid proxy %s: must include the host and port", u.Redacted())
+	}
+	return u, nil
+}
+
+// WithProxy connects to the SSH server through the proxy when the host does not set its own proxy.
+func WithProxy(proxyURL string) ClientOption {
+	return func(c *Client) {
+		c.proxy = proxyURL
+	}
+}
+
+// proxyURL returns the proxy to connect through, nil when connecting directly.
+func (c *Client) proxyURL() (*url.URL, error) {
+	if c.info.Proxy != "" {
+		return ParseProxy(c.info.Proxy)
+	}
+	return ParseProxy(c.proxy)
+}
+
+// dialProxy connects to addr through the proxy.
+func dialProxy(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	if proxyURL.Scheme == "http" {
+		return dialHTTPConnect(ctx, proxyURL, addr)
+	}
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		pass, _ := proxyURL.User.Password()
+		auth = &proxy.Auth{User: proxyURL.User.Username(), Password: pass}
+	}
+	dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, &net.Dialer{})
+	if err != nil {
+		return nil, err
+	}
+	return dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+}
+
+// dialHTTPConnect connects to addr through the HTTP proxy with the CONNECT method.
+func dialHTTPConnect(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	// closing the connection is the only way to interrupt the request
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	reader, err := httpConnect(conn, proxyURL, addr)
+	if !stop() {
+		_ = conn.Close()
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if reader.Buffered() > 0 {
+		// the SSH server already sent its version, it must not be lost
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// httpConnect sends the CONNECT request over the connection to the proxy and reads the response.
+func httpConnect(conn net.Conn, proxyURL *url.URL, addr string) (*bufio.Reader, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		pass, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + pass))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	err := req.Write(conn)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusProxyAuthRequired {
+			return nil, errors.New("proxy authentication failed")
+		}
+		return nil, fmt.Errorf("proxy refused to connect: %s", resp.Status)
+	}
+	return reader, nil
+}
+
+// bufferedConn is a connection that first reads what was already buffered from it.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
@@ -0,0 +1,375 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// testHandler handles an exec request on the test server and returns the exit status.
+type testHandler func(cmd string, ch ssh.Channel) uint32
+
+// testServer is an in-process SSH server used by the tests.
+type testServer struct {
+	info    ClientInfo
+	hostKey ssh.PublicKey
+	handler testHandler
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mx          sync.Mutex
+	conns       []net.Conn
+	connections int
+	signals     []string
+	ptys        []string
+	envs        []string
+	rejectEnv   bool
+}
+
+// newTestServer starts an SSH server that accepts user/pass and handles exec requests with the handler.
+//
+// The configuration of the server can be changed with the options (e.g. to only allow legacy algorithms).
+func newTestServer(t *testing.T, handler testHandler, opts ...func(cfg *ssh.ServerConfig)) *testServer {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("failed to create host key signer: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	s := &testServer{
+		info: ClientInfo{
+			Name: "test-" + port,
+			Host: host,
+			Port: port,
+			User: "user",
+			Pass: "pass",
+		},
+		hostKey:  signer.PublicKey(),
+		handler:  handler,
+		listener: listener,
+	}
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "user" && string(password) == "pass" {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	cfg.AddHostKey(signer)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn, cfg)
+			}()
+		}
+	}()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Close stops the server.
+func (s *testServer) Close() {
+	_ = s.listener.Close()
+	s.DropConnections()
+}
+
+// Connections returns the number of connections that have been accepted.
+func (s *testServer) Connections() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.connections
+}
+
+// Signals returns the signals that have been sent to the commands.
+func (s *testServer) Signals() []string {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return slices.Clone(s.signals)
+}
+
+// PTYs returns the TERM and size ("xterm 80x24") of the pseudo-terminals that have been requested.
+func (s *testServer) PTYs() []string {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return slices.Clone(s.ptys)
+}
+
+// Envs returns the environment variables ("NAME=value") that have been set on the sessions.
+func (s *testServer) Envs() []string {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return slices.Clone(s.envs)
+}
+
+// RejectEnv rejects setting environment variables like a server without AcceptEnv.
+func (s *testServer) RejectEnv() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.rejectEnv = true
+}
+
+// DropConnections closes all the open connections without a clean shutdown.
+func (s *testServer) DropConnections() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testServer) serve(conn net.Conn, cfg *ssh.ServerConfig) {
+	s.mx.Lock()
+	s.conns = append(s.conns, conn)
+	s.connections++
+	s.mx.Unlock()
+
+	serverConn, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	defer serverConn.Close()
+	go s.globalRequests(serverConn, reqs)
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			go s.session(newChannel)
+		case "direct-tcpip":
+			go s.directTCPIP(newChannel)
+		default:
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+		}
+	}
+}
+
+func (s *testServer) session(newChannel ssh.NewChannel) {
+	ch, reqs, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer ch.Close()
+	for req := range reqs {
+		switch req.Type {
+		case "exec":
+			var payload struct{ Command string }
+			_ = ssh.Unmarshal(req.Payload, &payload)
+			_ = req.Reply(true, nil)
+			// the handler runs while the requests are still handled so the command can be signalled
+			go func() {
+				status := s.handler(payload.Command, ch)
+				_, _ = ch.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, status))
+				_ = ch.Close()
+			}()
+		case "subsystem":
+			var payload struct{ Name string }
+			_ = ssh.Unmarshal(req.Payload, &payload)
+			if payload.Name != "sftp" {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+			// serves the local filesystem
+			go func() {
+				server, err := sftp.NewServer(ch)
+				if err == nil {
+					_ = server.Serve()
+					_ = server.Close()
+				}
+				_ = ch.Close()
+			}()
+		case "pty-req":
+			var payload struct {
+				Term     string
+				Columns  uint32
+				Rows     uint32
+				Width    uint32
+				Height   uint32
+				Modelist string
+			}
+			_ = ssh.Unmarshal(req.Payload, &payload)
+			s.mx.Lock()
+			s.ptys = append(s.ptys, fmt.Sprintf("%s %dx%d", payload.Term, payload.Columns, payload.Rows))
+			s.mx.Unlock()
+			_ = req.Reply(true, nil)
+		case "env":
+			var payload struct{ Name, Value string }
+			_ = ssh.Unmarshal(req.Payload, &payload)
+			s.mx.Lock()
+			reject := s.rejectEnv
+			if !reject {
+				s.envs = append(s.envs, payload.Name+"="+payload.Value)
+			}
+			s.mx.Unlock()
+			_ = req.Reply(!reject, nil)
+		case "signal":
+			var payload struct{ Signal string }
+			_ = ssh.Unmarshal(req.Payload, &payload)
+			s.mx.Lock()
+			s.signals = append(s.signals, payload.Signal)
+			s.mx.Unlock()
+		default:
+			_ = req.Reply(true, nil)
+		}
+	}
+}
+
+// globalRequests handles the remote port forwards (tcpip-forward) of the connection.
+func (s *testServer) globalRequests(serverConn *ssh.ServerConn, reqs <-chan *ssh.Request) {
+	listeners := make(map[string]net.Listener)
+	defer func() {
+		for _, listener := range listeners {
+			_ = listener.Close()
+		}
+	}()
+	for req := range reqs {
+		var payload struct {
+			Addr string
+			Port uint32
+		}
+		err := ssh.Unmarshal(req.Payload, &payload)
+		if err != nil {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		switch req.Type {
+		case "tcpip-forward":
+			listener, err := net.Listen("tcp", net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port))))
+			if err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			port := uint32(listener.Addr().(*net.TCPAddr).Port)
+			listeners[net.JoinHostPort(payload.Addr, strconv.Itoa(int(port)))] = listener
+			_ = req.Reply(true, ssh.Marshal(struct{ Port uint32 }{port}))
+			go s.forwardRemote(serverConn, listener, payload.Addr, port)
+		case "cancel-tcpip-forward":
+			key := net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port)))
+			if listener, ok := listeners[key]; ok {
+				_ = listener.Close()
+				delete(listeners, key)
+			}
+			_ = req.Reply(true, nil)
+		default:
+			if req.WantReply {
+				_ = req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+// forwardRemote opens a forwarded-tcpip channel to the client for every accepted connection.
+func (s *testServer) forwardRemote(serverConn *ssh.ServerConn, listener net.Listener, addr string, port uint32) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		origin := conn.RemoteAddr().(*net.TCPAddr)
+		ch, reqs, err := serverConn.OpenChannel("forwarded-tcpip", ssh.Marshal(struct {
+			Addr       string
+			Port       uint32
+			OriginAddr string
+			OriginPort uint32
+		}{addr, port, origin.IP.String(), uint32(origin.Port)}))
+		if err != nil {
+			_ = conn.Close()
+			continue
+		}
+		go ssh.DiscardRequests(reqs)
+		go func() {
+			_, _ = io.Copy(ch, conn)
+			_ = ch.CloseWrite()
+		}()
+		go func() {
+			_, _ = io.Copy(conn, ch)
+			_ = conn.Close()
+			_ = ch.Close()
+		}()
+	}
+}
+
+func (s *testServer) directTCPIP(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	err := ssh.Unmarshal(newChannel.ExtraData(), &payload)
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := newChannel.Accept()
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		_, _ = io.Copy(ch, conn)
+		_ = ch.CloseWrite()
+	}()
+	_, _ = io.Copy(conn, ch)
+	_ = conn.Close()
+	_ = ch.Close()
+}
+
+// blockingHandler blocks on the "hang" command until the test finishes and echoes the other commands.
+func blockingHandler(t *testing.T) testHandler {
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		close(done)
+	})
+	return func(cmd string, ch ssh.Channel) uint32 {
+		if cmd != "hang" {
+			return echoHandler(cmd, ch)
+		}
+		<-done
+		return 0
+	}
+}
+
+// echoHandler writes the command back to the client.
+func echoHandler(cmd string, ch ssh.Channel) uint32 {
+	_, _ = io.WriteString(ch, cmd)
+	return 0
+}
@@ -0,0 +1,337 @@
+package ssh
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// TransferResult is the result of transferring a file over SFTP.
+type TransferResult struct {
+	RemotePath string `json:"remote_path"`
+	LocalPath  string `json:"local_path"`
+	Bytes      int64  `json:"bytes"`
+	SHA256     string `json:"sha256"`
+	Mode       string `json:"mode"`
+}
+
+// Upload copies the local file to the remote path over SFTP, preserving its mode bits.
+//
+// When the remote path is an existing directory the file is copied into it with the same name.
+func (c *Client) Upload(ctx context.Context, localPath string, remotePath string) (*TransferResult, error) {
+	sftpClient, stop, err := c.sftp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	local, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer local.Close()
+	info, err := local.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", localPath)
+	}
+
+	if remoteInfo, err := sftpClient.Stat(remotePath); err == nil && remoteInfo.IsDir() {
+		remotePath = path.Join(remotePath, filepath.Base(localPath))
+	}
+	remote, err := sftpClient.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remote file %s: %w", remotePath, err)
+	}
+	defer remote.Close()
+	// the mode is set before any data is written, so the content is never readable with a wider mode
+	err = remote.Chmod(info.Mode().Perm())
+	if err != nil {
+		return nil, transferError(ctx, fmt.Errorf("failed to set the mode of %s: %w", remotePath, err))
+	}
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(remote, hash), local)
+	if err != nil {
+		return nil, transferError(ctx, fmt.Errorf("failed to upload to %s: %w", remotePath, err))
+	}
+	err = remote.Close()
+	if err != nil {
+		return nil, transferError(ctx, fmt.Errorf("failed to upload to %s: %w", remotePath, err))
+	}
+	return &TransferResult{
+		RemotePath: remotePath,
+		LocalPath:  localPath,
+		Bytes:      written,
+		SHA256:     hex.EncodeToString(hash.Sum(nil)),
+		Mode:       info.Mode().Perm().String(),
+	}, nil
+}
+
+// Download copies the remote file to the local path over SFTP, preserving its mode bits.
+//
+// The directory of the local path is created when it does not exist.
+func (c *Client) Download(ctx context.Context, remotePath string, localPath string) (*TransferResult, error) {
+	sftpClient, stop, err := c.sftp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	remote, err := sftpClient.Open(remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open remote file %s: %w", remotePath, err)
+	}
+	defer remote.Close()
+	info, err := remote.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", remotePath)
+	}
+
+	err = os.MkdirAll(filepath.Dir(localPath), 0700)
+	if err != nil {
+		return nil, err
+	}
+	local, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return nil, err
+	}
+	defer local.Close()
+	// the mode of an existing file is not changed by opening it, set it before any data is written
+	err = local.Chmod(info.Mode().Perm())
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(local, hash), remote)
+	if err != nil {
+		return nil, transferError(ctx, fmt.Errorf("failed to download %s: %w", remotePath, err))
+	}
+	err = local.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &TransferResult{
+		RemotePath: remotePath,
+		LocalPath:  localPath,
+		Bytes:      written,
+		SHA256:     hex.EncodeToString(hash.Sum(nil)),
+		Mode:       info.Mode().Perm().String(),
+	}, nil
+}
+
+// sftp starts an SFTP session that is closed when the context is done or stop is called.
+func (c *Client) sftp(ctx context.Context) (*sftp.Client, func(), error) {
+	if c.client == nil {
+		return nil, nil, ErrNotConnected
+	}
+	sftpClient, err := sftp.NewClient(c.client)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start sftp: %w", err)
+	}
+	cancel := context.AfterFunc(ctx, func() {
+		_ = sftpClient.Close()
+	})
+	return sftpClient, func() {
+		cancel()
+		_ = sftpClient.Close()
+	}, nil
+}
+
+// transferError returns the reason the context is done when the transfer was interrupted by it.
+func transferError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("transfer cancelled: %w", ctx.Err())
+	}
+	return err
+}
+
+// FileContent is part of a remote file read over SFTP.
+type FileContent struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Offset int64  `json:"offset"`
+	Mode   string `json:"mode"`
+	Data   []byte `json:"-"`
+
+	// Truncated is true when the file continues after the returned data.
+	Truncated bool `json:"truncated"`
+}
+
+// ReadFile reads at most limit bytes of the remote file starting at offset.
+//
+// A missing file returns an error that matches fs.ErrNotExist.
+func (c *Client) ReadFile(ctx context.Context, remotePath string, offset int64, limit int64) (*FileContent, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("invalid offset %d or limit %d", offset, limit)
+	}
+	sftpClient, stop, err := c.sftp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	remote, err := sftpClient.Open(remotePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open remote file %s: %w", remotePath, err)
+	}
+	defer remote.Close()
+	info, err := remote.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", remotePath)
+	}
+
+	content := &FileContent{
+		Path:   remotePath,
+		Size:   info.Size(),
+		Offset: offset,
+		Mode:   info.Mode().Perm().String(),
+	}
+	if offset >= info.Size() {
+		return content, nil
+	}
+	_, err = remote.Seek(offset, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	content.Data, err = io.ReadAll(io.LimitReader(remote, limit))
+	if err != nil {
+		return nil, transferError(ctx, fmt.Errorf("failed to read %s: %w", remotePath, err))
+	}
+	content.Truncated = offset+int64(len(content.Data)) < info.Size()
+	return content, nil
+}
+
+// WriteResult is the result of writing a remote file over SFTP.
+type WriteResult struct {
+	Path       string `json:"path"`
+	Bytes      int64  `json:"bytes"`
+	Created    bool   `json:"created"`
+	BackupPath string `json:"backup_path,omitempty"`
+}
+
+// WriteFile atomically replaces the remote file with the data.
+//
+// The data is written to a temporary file next to the file that is then renamed over it, so the file is
+// never partially written. An existing file keeps its mode bits (and its owner when allowed) and is copied
+// to a timestamped .bak file first when backup is true. New files are created with mode 0644.
+func (c *Client) WriteFile(ctx context.Context, remotePath string, data []byte, backup bool) (*WriteResult, error) {
+	sftpClient, stop, err := c.sftp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	result := &WriteResult{
+		Path:  remotePath,
+		Bytes: int64(len(data)),
+	}
+	mode := os.FileMode(0644)
+	existing, err := sftpClient.Stat(remotePath)
+	switch {
+	case err == nil:
+		if existing.IsDir() {
+			return nil, fmt.Errorf("%s is a directory", remotePath)
+		}
+		mode = existing.Mode().Perm()
+	case errors.Is(err, fs.ErrNotExist):
+		existing = nil
+		result.Created = true
+	default:
+		return nil, fmt.Errorf("failed to stat %s: %w", remotePath, err)
+	}
+
+	if existing != nil && backup {
+		result.BackupPath = fmt.Sprintf("%s.%s.bak", remotePath, time.Now().Format("20060102T150405"))
+		err = copyRemoteFile(sftpClient, remotePath, result.BackupPath, mode)
+		if err != nil {
+			return nil, transferError(ctx, fmt.Errorf("failed to back up %s: %w", remotePath, err))
+		}
+	}
+
+	tmpPath := path.Join(path.Dir(remotePath), fmt.Sprintf(".%s.sshai-%s", path.Base(remotePath), randomSuffix()))
+	tmp, err := sftpClient.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file %s: %w", tmpPath, err)
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		_ = tmp.Close()
+	}
+	if err == nil {
+		err = sftpClient.Chmod(tmpPath, mode)
+	}
+	if err != nil {
+		_ = sftpClient.Remove(tmpPath)
+		return nil, transferError(ctx, fmt.Errorf("failed to write %s: %w", tmpPath, err))
+	}
+	if existing != nil {
+		if stat, ok := existing.Sys().(*sftp.FileStat); ok {
+			// only possible when connected as root or the owner is the same user
+			_ = sftpClient.Chown(tmpPath, int(stat.UID), int(stat.GID))
+		}
+	}
+
+	err = sftpClient.PosixRename(tmpPath, remotePath)
+	if err != nil && existing == nil {
+		// without the posix-rename extension a plain rename still works for a new file
+		err = sftpClient.Rename(tmpPath, remotePath)
+	}
+	if err != nil {
+		_ = sftpClient.Remove(tmpPath)
+		return nil, transferError(ctx, fmt.Errorf("failed to replace %s: %w", remotePath, err))
+	}
+	return result, nil
+}
+
+// copyRemoteFile copies the remote file to the destination on the same server.
+func copyRemoteFile(sftpClient *sftp.Client, src string, dst string, mode os.FileMode) error {
+	in, err := sftpClient.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := sftpClient.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return err
+	}
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+	return sftpClient.Chmod(dst, mode)
+}
+
+// randomSuffix returns a random suffix for temporary file names.
+func randomSuffix() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,171 @@
+package ssh
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func connectTestClient(t *testing.T, server *testServer) *Client {
+	client := NewClient(&server.info, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return client
+}
+
+func TestClient_UploadDownload(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	client := connectTestClient(t, server)
+
+	content := []byte("listen 8080\n")
+	sum := sha256.Sum256(content)
+	dir := t.TempDir()
+	localPath := filepath.Join(dir, "app.conf")
+	err := os.WriteFile(localPath, content, 0640)
+	if err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	// uploading into a directory keeps the name of the file
+	remoteDir := t.TempDir()
+	uploaded, err := client.Upload(context.Background(), localPath, remoteDir)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	remotePath := filepath.Join(remoteDir, "app.conf")
+	if uploaded.RemotePath != remotePath {
+		t.Errorf("expected remote path %s, got %s", remotePath, uploaded.RemotePath)
+	}
+	if uploaded.Bytes != int64(len(content)) || uploaded.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected %d bytes with checksum %x, got %d bytes with %s", len(content), sum, uploaded.Bytes, uploaded.SHA256)
+	}
+	info, err := os.Stat(remotePath)
+	if err != nil {
+		t.Fatalf("expected uploaded file, got %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("expected mode 0640 to be preserved, got %v", info.Mode().Perm())
+	}
+
+	downloadPath := filepath.Join(t.TempDir(), "host", "app.conf")
+	downloaded, err := client.Download(context.Background(), remotePath, downloadPath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if downloaded.SHA256 != uploaded.SHA256 {
+		t.Errorf("expected the same checksum, got %s and %s", uploaded.SHA256, downloaded.SHA256)
+	}
+	got, err := os.ReadFile(downloadPath)
+	if err != nil || string(got) != string(content) {
+		t.Errorf("expected downloaded content %q, got %q (%v)", content, got, err)
+	}
+	info, err = os.Stat(downloadPath)
+	if err != nil {
+		t.Fatalf("expected downloaded file, got %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("expected downloaded mode 0640, got %v", info.Mode().Perm())
+	}
+}
+
+func TestClient_DownloadMissing(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	client := connectTestClient(t, server)
+
+	_, err := client.Download(context.Background(), filepath.Join(t.TempDir(), "missing"), filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Fatalf("expected error for missing remote file")
+	}
+}
+
+func TestClient_ReadFile(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	client := connectTestClient(t, server)
+
+	remotePath := filepath.Join(t.TempDir(), "log")
+	err := os.WriteFile(remotePath, []byte("0123456789"), 0600)
+	if err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	content, err := client.ReadFile(context.Background(), remotePath, 2, 5)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if string(content.Data) != "23456" || !content.Truncated || content.Size != 10 {
+		t.Errorf("expected truncated 23456 of 10 bytes, got %q (truncated %v, size %d)", content.Data, content.Truncated, content.Size)
+	}
+
+	content, err = client.ReadFile(context.Background(), remotePath, 7, 5)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if string(content.Data) != "789" || content.Truncated {
+		t.Errorf("expected 789 to the end, got %q (truncated %v)", content.Data, content.Truncated)
+	}
+
+	_, err = client.ReadFile(context.Background(), filepath.Join(t.TempDir(), "missing"), 0, 5)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
+
+func TestClient_WriteFile(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	client := connectTestClient(t, server)
+
+	dir := t.TempDir()
+	remotePath := filepath.Join(dir, "app.conf")
+	written, err := client.WriteFile(context.Background(), remotePath, []byte("first\n"), true)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !written.Created || written.BackupPath != "" {
+		t.Errorf("expected a new file without backup, got %+v", written)
+	}
+
+	err = os.Chmod(remotePath, 0600)
+	if err != nil {
+		t.Fatalf("failed to chmod: %v", err)
+	}
+	written, err = client.WriteFile(context.Background(), remotePath, []byte("second\n"), true)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if written.Created || written.Bytes != 7 {
+		t.Errorf("expected 7 bytes replacing the file, got %+v", written)
+	}
+	got, _ := os.ReadFile(remotePath)
+	if string(got) != "second\n" {
+		t.Errorf("expected replaced content, got %q", got)
+	}
+	info, err := os.Stat(remotePath)
+	if err != nil {
+		t.Fatalf("expected file, got %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600 to be kept, got %v", info.Mode().Perm())
+	}
+	backup, _ := os.ReadFile(written.BackupPath)
+	if string(backup) != "first\n" {
+		t.Errorf("expected backup with the previous content, got %q", backup)
+	}
+
+	// only the file and its backup remain, the temporary file is renamed
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("expected the file and its backup, got %d entries", len(entries))
+	}
+}
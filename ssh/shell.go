@@ -0,0 +1,85 @@
+package ssh
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// ShellPOSIX is the shell of Unix-like hosts (sh, bash, ...), the default when the shell is not known.
+	ShellPOSIX = "sh"
+	// ShellPowerShell is the shell of Windows hosts whose OpenSSH DefaultShell is PowerShell.
+	ShellPowerShell = "powershell"
+	// ShellCmd is the default shell of Windows hosts running OpenSSH.
+	ShellCmd = "cmd"
+)
+
+// envNamePattern matches the names of environment variables that a shell accepts.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateEnv returns an error when a name in the environment is not a valid environment variable name.
+func ValidateEnv(env map[string]string) error {
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+	}
+	return nil
+}
+
+// shellQuote quotes the value so the remote shell treats it as a single word.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// powerShellQuote quotes the value as a PowerShell string literal.
+func powerShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// withShellPrefix prefixes the command to change into the directory and set the environment with the shell.
+func withShellPrefix(shell string, cmd string, dir string, env map[string]string) (string, error) {
+	names := slices.Sorted(maps.Keys(env))
+	switch shell {
+	case ShellPowerShell:
+		var prefix strings.Builder
+		for _, name := range names {
+			fmt.Fprintf(&prefix, "$env:%s = %s; ", name, powerShellQuote(env[name]))
+		}
+		if dir != "" {
+			fmt.Fprintf(&prefix, "Set-Location -LiteralPath %s; ", powerShellQuote(dir))
+		}
+		return prefix.String() + cmd, nil
+	case ShellCmd:
+		// cmd.exe has no escaping inside quotes
+		var prefix strings.Builder
+		for _, name := range names {
+			if strings.ContainsAny(env[name], `"%`) {
+				return "", fmt.Errorf("environment variable %s cannot be set by cmd.exe: the value contains \" or %%", name)
+			}
+			fmt.Fprintf(&prefix, `set "%s=%s" && `, name, env[name])
+		}
+		if dir != "" {
+			if strings.Contains(dir, `"`) {
+				return "", fmt.Errorf("directory %s cannot be used by cmd.exe: it contains \"", dir)
+			}
+			fmt.Fprintf(&prefix, `cd /d "%s" && `, dir)
+		}
+		return prefix.String() + cmd, nil
+	}
+	if len(env) > 0 {
+		assignments := make([]string, 0, len(env))
+		for _, name := range names {
+			assignments = append(assignments, shellQuote(name+"="+env[name]))
+		}
+		// exported in the shell that runs the command, so it keeps running in the login shell of the user
+		cmd = fmt.Sprintf("export %s && %s", strings.Join(assignments, " "), cmd)
+	}
+	if dir != "" {
+		cmd = fmt.Sprintf("cd %s && %s", shellQuote(dir), cmd)
+	}
+	return cmd, nil
+}
@@ -1,9 +1,13 @@
 package ssh
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
+	"strconv"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -11,12 +15,25 @@ import (
 // ErrNotConnected returned when the client is not connected.
 var ErrNotConnected = errors.New("not connected")
 
+// ErrNoAuthMethod returned when the client has no authentication method configured.
+var ErrNoAuthMethod = errors.New("no authentication method configured: provide a password, a private key or use the ssh-agent")
+
+// ErrNoKeyboardInteractive returned when keyboard-interactive is configured but the challenges cannot be answered.
+var ErrNoKeyboardInteractive = errors.New("keyboard-interactive authentication requires the challenges to be answered by the user")
+
+// ErrNoAgent returned when the ssh-agent is requested but SSH_AUTH_SOCK is not set.
+var ErrNoAgent = errors.New("ssh-agent requested but SSH_AUTH_SOCK is not set")
+
+// DefaultDialTimeout is how long to wait for the TCP connection to the SSH server when no connect timeout is set.
+const DefaultDialTimeout = 30 * time.Second
+
 // OSInfo provides the OS information.
 type OSInfo struct {
 	Name     string `yaml:"name" json:"name" jsonschema_description:"The name of the operating system"`
 	Platform string `yaml:"platform" json:"platform" jsonschema_description:"The platform of the operating system"`
 	Version  string `yaml:"version" json:"version" jsonschema_description:"The version of the operating system"`
 	Arch     string `yaml:"arch" json:"arch" jsonschema_description:"The architecture of the operating system"`
+	Shell    string `yaml:"shell,omitempty" json:"shell,omitempty" jsonschema_description:"The shell that runs the commands: sh, powershell or cmd"`
 }
 
 // ClientInfo stores the generate client information.
@@ -25,9 +42,38 @@ type ClientInfo struct {
 	Host string `yaml:"host" json:"host" jsonschema_description:"The host of the client"`
 	Port string `yaml:"port" json:"port" jsonschema_description:"The port of the client"`
 	User string `yaml:"user" json:"user" jsonschema_description:"The user of the client"`
-	Pass string `yaml:"pass" json:"pass" jsonschema_description:"The password of the client"`
+	// secrets are only stored, they are never part of the JSON in tool results
+	Pass string `yaml:"pass" json:"-" jsonschema_description:"The password of the client"`
+
+	KeyPath       string `yaml:"key_path,omitempty" json:"key_path,omitempty" jsonschema_description:"The path to the private key of the client"`
+	KeyPassphrase string `yaml:"key_passphrase,omitempty" json:"-" jsonschema_description:"The passphrase of the private key of the client"`
+	CertPath      string `yaml:"cert_path,omitempty" json:"cert_path,omitempty" jsonschema_description:"The path to the user certificate of the client (defaults to the -cert.pub next to the private key)"`
+
+	KeyboardInteractive bool `yaml:"keyboard_interactive,omitempty" json:"keyboard_interactive,omitempty" jsonschema_description:"Authenticate using keyboard-interactive (e.g. one-time passwords) with the challenges answered by the user"`
+
+	UseAgent     bool `yaml:"use_agent,omitempty" json:"use_agent,omitempty" jsonschema_description:"Authenticate using the ssh-agent from SSH_AUTH_SOCK"`
+	ForwardAgent bool `yaml:"forward_agent,omitempty" json:"forward_agent,omitempty" jsonschema_description:"Forward the ssh-agent to the remote host"`
+
+	BecomeMethod string `yaml:"become_method,omitempty" json:"become_method,omitempty" jsonschema_description:"How commands become another user: sudo (default), su or doas"`
+	BecomePass   string `yaml:"become_pass,omitempty" json:"-" jsonschema_description:"The password for becoming another user (defaults to the password of the client)"`
+
+	Env map[string]string `yaml:"env,omitempty" json:"env,omitempty" jsonschema_description:"The environment variables set for every command on the client"`
+
+	Jump []string `yaml:"jump,omitempty" json:"jump,omitempty" jsonschema_description:"The jump hosts (names of stored hosts or SSH connection strings) to connect through in order"`
+
+	Ciphers           []string `yaml:"ciphers,omitempty" json:"ciphers,omitempty" jsonschema_description:"The ciphers allowed for the client in order of preference (prefix the first with + to add them to the defaults)"`
+	KeyExchanges      []string `yaml:"key_exchanges,omitempty" json:"key_exchanges,omitempty" jsonschema_description:"The key exchanges allowed for the client in order of preference (prefix the first with + to add them to the defaults)"`
+	MACs              []string `yaml:"macs,omitempty" json:"macs,omitempty" jsonschema_description:"The MACs allowed for the client in order of preference (prefix the first with + to add them to the defaults)"`
+	HostKeyAlgorithms []string `yaml:"host_key_algorithms,omitempty" json:"host_key_algorithms,omitempty" jsonschema_description:"The host key algorithms allowed for the client in order of preference (prefix the first with + to add them to the defaults)"`
+
+	Proxy string `yaml:"proxy,omitempty" json:"proxy,omitempty" jsonschema_description:"The SOCKS5 or HTTP CONNECT proxy (with its credentials) to connect through, none to connect directly"`
+
+	ConnectTimeout string `yaml:"connect_timeout,omitempty" json:"connect_timeout,omitempty" jsonschema_description:"How long to wait for the connection to the client (e.g. 10s)"`
+	CommandTimeout string `yaml:"command_timeout,omitempty" json:"command_timeout,omitempty" jsonschema_description:"How long a command can run on the client before it is cancelled (e.g. 5m), background jobs are not limited"`
 
 	OS OSInfo `yaml:"os" json:"os" jsonschema_description:"The operating system information"`
+
+	Algorithms *NegotiatedAlgorithms `yaml:"negotiated_algorithms,omitempty" json:"negotiated_algorithms,omitempty" jsonschema_description:"The algorithms negotiated on the last connection to the client"`
 }
 
 // NewClientInfo returns client information from the connection string.
@@ -48,9 +94,6 @@ func NewClientInfo(name string, connStr string) (*ClientInfo, error) {
 		return nil, errors.New("invalid SSH connection string: missing username")
 	}
 	pass, _ := sshURL.User.Password()
-	if pass == "" {
-		return nil, errors.New("invalid SSH connection string: missing password")
-	}
 	host := sshURL.Hostname()
 	if host == "" {
 		return nil, errors.New("invalid SSH connection string: missing host")
@@ -73,57 +116,321 @@ func NewClientInfo(name string, connStr string) (*ClientInfo, error) {
 	}, nil
 }
 
+// Shell returns the shell that runs the commands on the client, ShellPOSIX when it is not known.
+func (info *ClientInfo) Shell() string {
+	if info.OS.Shell == "" {
+		return ShellPOSIX
+	}
+	return info.OS.Shell
+}
+
+// Redacted returns a copy of the client information to show in results, the credentials in the
+// proxy URL and in jump host connection strings are replaced (the other secrets are never shown).
+func (info ClientInfo) Redacted() ClientInfo {
+	info.Proxy = redactURL(info.Proxy)
+	if len(info.Jump) > 0 {
+		jumps := make([]string, len(info.Jump))
+		for i, jump := range info.Jump {
+			jumps[i] = redactURL(jump)
+		}
+		info.Jump = jumps
+	}
+	return info
+}
+
+// redactURL replaces the password in the URL, values that are not URLs with credentials are kept.
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	return u.Redacted()
+}
+
+// ParseTimeout parses a timeout that is either a duration (e.g. 30s or 5m) or a number of seconds.
+//
+// An empty timeout is zero, which means no timeout.
+func ParseTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(timeout)
+		if convErr != nil {
+			return 0, fmt.Errorf("invalid timeout %q: %w", timeout, err)
+		}
+		d = time.Duration(seconds) * time.Second
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid timeout %q: must not be negative", timeout)
+	}
+	return d, nil
+}
+
+// withTimeout applies the timeout to the context.
+//
+// A deadline the context already has is kept when it is earlier, so the timeout of a single call
+// can shorten the timeout configured on the host but never extend it.
+func withTimeout(ctx context.Context, timeout string) (context.Context, context.CancelFunc, error) {
+	d, err := ParseTimeout(timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d == 0 {
+		return ctx, func() {}, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, d)
+	return ctx, cancel, nil
+}
+
 // Client is an SSH client.
 type Client struct {
 	info *ClientInfo
 
-	client *ssh.Client
+	knownHosts      *KnownHosts
+	trustOnFirstUse bool
+	hostKeyCallback ssh.HostKeyCallback
+	jump            *Client
+	proxy           string
+	challenge       KeyboardInteractiveChallenge
+
+	client    *ssh.Client
+	agentConn net.Conn
+}
+
+// ClientOption is an option for the client.
+type ClientOption func(*Client)
+
+// WithKnownHosts verifies the host key against the known_hosts file.
+//
+// When trustOnFirstUse is true the host key is recorded if the host is not already known, which only
+// happens once the connection is authenticated.
+func WithKnownHosts(knownHosts *KnownHosts, trustOnFirstUse bool) ClientOption {
+	return func(c *Client) {
+		c.knownHosts = knownHosts
+		c.trustOnFirstUse = trustOnFirstUse
+	}
+}
+
+// WithHostKeyCallback verifies the host key with the callback (takes precedence over WithKnownHosts).
+func WithHostKeyCallback(callback ssh.HostKeyCallback) ClientOption {
+	return func(c *Client) {
+		c.hostKeyCallback = callback
+	}
+}
+
+// WithJump connects to the SSH server through the already connected jump client.
+func WithJump(jump *Client) ClientOption {
+	return func(c *Client) {
+		c.jump = jump
+	}
+}
+
+// WithKeyboardInteractive answers the keyboard-interactive challenges with the callback.
+func WithKeyboardInteractive(challenge KeyboardInteractiveChallenge) ClientOption {
+	return func(c *Client) {
+		c.challenge = challenge
+	}
 }
 
 // NewClient creates the client with the hostPort and configuration.
-func NewClient(info *ClientInfo) *Client {
-	return &Client{
+func NewClient(info *ClientInfo, opts ...ClientOption) *Client {
+	c := &Client{
 		info: info,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Connect connects to the SSH server.
-func (c *Client) Connect() error {
-	var err error
-	host := fmt.Sprintf("%s:%s", c.info.Host, c.info.Port)
+//
+// The connect timeout of the host applies, or the deadline of the context when it is earlier.
+func (c *Client) Connect(ctx context.Context) error {
+	ctx, cancel, err := withTimeout(ctx, c.info.ConnectTimeout)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	auth, err := c.authMethods()
+	if err != nil {
+		c.closeAgent()
+		return err
+	}
 	cfg := &ssh.ClientConfig{
-		User: c.info.User,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(c.info.Pass),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		User:            c.info.User,
+		Auth:            auth,
+		HostKeyCallback: c.hostKeyCallback,
+	}
+	var pending *HostKey
+	if cfg.HostKeyCallback == nil && c.knownHosts != nil {
+		var onUnknown func(hostKey *HostKey)
+		if c.trustOnFirstUse {
+			onUnknown = func(hostKey *HostKey) {
+				pending = hostKey
+			}
+		}
+		cfg.HostKeyCallback = c.knownHosts.HostKeyCallback(onUnknown)
+		cfg.HostKeyAlgorithms = c.knownHosts.HostKeyAlgorithms(c.address())
+	}
+	err = c.applyAlgorithms(cfg)
+	if err != nil {
+		c.closeAgent()
+		return err
 	}
-	c.client, err = ssh.Dial("tcp", host, cfg)
+	c.client, err = c.dial(ctx, cfg)
 	if err != nil {
+		c.closeAgent()
 		return fmt.Errorf("failed to connect to SSH server: %w", err)
 	}
+	// the key of an unknown host is only trusted once authenticated, so a failed connection never records it
+	if pending != nil {
+		err = c.knownHosts.Trust(pending)
+		if err != nil {
+			_ = c.Close()
+			return err
+		}
+	}
+	if c.info.ForwardAgent {
+		err = c.forwardAgent()
+		if err != nil {
+			_ = c.Close()
+			return err
+		}
+	}
 	return nil
 }
 
-// Close closes the connection to the SSH server.
-func (c *Client) Close() error {
-	if c.client != nil {
-		return c.client.Close()
+// ScanHostKey connects to the SSH server only to retrieve the host key that it presents.
+func (c *Client) ScanHostKey(ctx context.Context) (*HostKey, error) {
+	ctx, cancel, err := withTimeout(ctx, c.info.ConnectTimeout)
+	if err != nil {
+		return nil, err
 	}
-	return nil
-}
+	defer cancel()
 
-// Exec runs a command on the remote SSH server.
-func (c *Client) Exec(cmd string) ([]byte, error) {
-	session, err := c.client.NewSession()
+	var hostKey *HostKey
+	cfg := &ssh.ClientConfig{
+		User: c.info.User,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKey = &HostKey{
+				Host:        hostname,
+				Type:        key.Type(),
+				Fingerprint: ssh.FingerprintSHA256(key),
+				remote:      remote,
+				key:         key,
+			}
+			return errHostKeyScanned
+		},
+	}
+	// like Connect, a server with several host keys presents the type that is known for it
+	if c.knownHosts != nil {
+		cfg.HostKeyAlgorithms = c.knownHosts.HostKeyAlgorithms(c.address())
+	}
+	err = c.applyAlgorithms(cfg)
 	if err != nil {
 		return nil, err
 	}
-	defer session.Close()
+	client, err := c.dial(ctx, cfg)
+	if client != nil {
+		_ = client.Close()
+	}
+	if hostKey == nil {
+		return nil, fmt.Errorf("failed to retrieve host key: %w", err)
+	}
+	return hostKey, nil
+}
+
+// dial connects to the SSH server with the configuration.
+//
+// When a jump client is set the connection is tunneled through it with direct-tcpip, otherwise it
+// is made through the proxy (if any). The handshake is aborted when the context is done.
+func (c *Client) dial(ctx context.Context, cfg *ssh.ClientConfig) (*ssh.Client, error) {
+	addr := c.address()
+	dialCtx, cancel := context.WithTimeout(ctx, DefaultDialTimeout)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	if c.jump == nil {
+		proxyURL, err := c.proxyURL()
+		if err != nil {
+			return nil, err
+		}
+		if proxyURL != nil {
+			conn, err = dialProxy(dialCtx, proxyURL, addr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to dial through proxy %s: %w", proxyURL.Redacted(), err)
+			}
+		} else {
+			var dialer net.Dialer
+			conn, err = dialer.DialContext(dialCtx, "tcp", addr)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if c.jump.client == nil {
+			return nil, fmt.Errorf("jump host %s: %w", c.jump.info.Name, ErrNotConnected)
+		}
+		conn, err = c.jump.client.DialContext(dialCtx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to dial through jump host %s: %w", c.jump.info.Name, err)
+		}
+	}
 
-	output, err := session.CombinedOutput(cmd)
+	// closing the connection is the only way to interrupt the handshake
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+	if !stop() {
+		if err == nil {
+			_ = clientConn.Close()
+		}
+		return nil, ctx.Err()
+	}
 	if err != nil {
+		_ = conn.Close()
 		return nil, err
 	}
-	return output, nil
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
+
+// address returns the host:port of the SSH server.
+func (c *Client) address() string {
+	return net.JoinHostPort(c.info.Host, c.info.Port)
+}
+
+// keepAliveTimeout is how long to wait for the reply to a keepalive.
+const keepAliveTimeout = 15 * time.Second
+
+// alive returns true when the SSH server still replies to a keepalive.
+func (c *Client) alive() bool {
+	if c.client == nil {
+		return false
+	}
+	result := make(chan error, 1)
+	go func() {
+		_, _, err := c.client.SendRequest("keepalive@openssh.com", true, nil)
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		return err == nil
+	case <-time.After(keepAliveTimeout):
+		return false
+	}
+}
+
+// Close closes the connection to the SSH server.
+func (c *Client) Close() error {
+	c.closeAgent()
+	if c.client != nil {
+		return c.client.Close()
+	}
+	return nil
 }
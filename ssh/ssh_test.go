@@ -1,7 +1,16 @@
 package ssh
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"slices"
 	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
 )
 
 func TestNewClientInfo_ValidConnectionString(t *testing.T) {
@@ -63,10 +72,13 @@ func TestNewClientInfo_MissingUsername(t *testing.T) {
 }
 
 func TestNewClientInfo_MissingPassword(t *testing.T) {
-	connStr := "ssh://user:@host:22"
-	_, err := NewClientInfo("test", connStr)
-	if err == nil || err.Error() != "invalid SSH connection string: missing password" {
-		t.Errorf("expected error for missing password, got %v", err)
+	connStr := "ssh://user@host:22"
+	info, err := NewClientInfo("test", connStr)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if info.Pass != "" {
+		t.Errorf("expected empty pass, got '%s'", info.Pass)
 	}
 }
 
@@ -85,3 +97,412 @@ func TestNewClientInfo_InvalidURL(t *testing.T) {
 		t.Errorf("expected error for invalid URL, got nil")
 	}
 }
+
+func TestParseTimeout(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":    0,
+		"30s": 30 * time.Second,
+		"5m":  5 * time.Minute,
+		"10":  10 * time.Second,
+	}
+	for timeout, expected := range tests {
+		got, err := ParseTimeout(timeout)
+		if err != nil {
+			t.Fatalf("expected no error for %q, got %v", timeout, err)
+		}
+		if got != expected {
+			t.Errorf("expected %v for %q, got %v", expected, timeout, got)
+		}
+	}
+	for _, timeout := range []string{"soon", "-5s"} {
+		_, err := ParseTimeout(timeout)
+		if err == nil {
+			t.Errorf("expected error for %q", timeout)
+		}
+	}
+}
+
+func TestClient_ConnectTimeout(t *testing.T) {
+	// accepts connections but never completes the handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	info := &ClientInfo{Host: host, Port: port, User: "user", Pass: "pass", ConnectTimeout: "100ms"}
+	client := NewClient(info, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	start := time.Now()
+	err = client.Connect(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected connect to time out quickly, took %v", elapsed)
+	}
+}
+
+func TestClient_ExecCommandTimeout(t *testing.T) {
+	server := newTestServer(t, blockingHandler(t))
+	info := server.info
+	info.CommandTimeout = "100ms"
+	client := NewClient(&info, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer client.Close()
+
+	_, err = client.Exec(context.Background(), "hang")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	waitForSignal(t, server, "TERM")
+}
+
+func TestClient_ExecCancelled(t *testing.T) {
+	server := newTestServer(t, blockingHandler(t))
+	client := NewClient(&server.info, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, err = client.Exec(ctx, "hang")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled, got %v", err)
+	}
+	waitForSignal(t, server, "TERM")
+
+	// the connection is still usable after a command is cancelled
+	_, err = client.Exec(context.Background(), "true")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestClient_ExecContextKeepsCommandTimeout(t *testing.T) {
+	server := newTestServer(t, blockingHandler(t))
+	info := server.info
+	info.CommandTimeout = "100ms"
+	client := NewClient(&info, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer client.Close()
+
+	// a later deadline of the context does not extend the command timeout of the host
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err = client.Exec(ctx, "hang")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the command timeout to apply, took %v", elapsed)
+	}
+	waitForSignal(t, server, "TERM")
+
+	// an earlier deadline of the context still applies
+	info.CommandTimeout = "5s"
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err = client.Exec(ctx, "hang")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the deadline of the context to apply, took %v", elapsed)
+	}
+}
+
+// waitForSignal waits for the test server to receive the signal.
+func waitForSignal(t *testing.T, server *testServer, signal string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if slices.Contains(server.Signals(), signal) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected signal %s, got %v", signal, server.Signals())
+}
+
+func TestClient_ExecResult(t *testing.T) {
+	server := newTestServer(t, func(cmd string, ch ssh.Channel) uint32 {
+		_, _ = ch.Write([]byte("out"))
+		_, _ = ch.Stderr().Write([]byte("not found\n"))
+		if cmd == "killed" {
+			_, _ = ch.SendRequest("exit-signal", false, ssh.Marshal(struct {
+				Signal     string
+				CoreDumped bool
+				Error      string
+				Lang       string
+			}{Signal: "KILL"}))
+			return 137
+		}
+		return 3
+	})
+	client := NewClient(&server.info, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer client.Close()
+
+	result, err := client.Exec(context.Background(), "missing")
+	if err != nil {
+		t.Fatalf("expected no error for a non-zero exit, got %v", err)
+	}
+	if result.Stdout != "out" || result.Stderr != "not found\n" {
+		t.Errorf("expected separate stdout and stderr, got '%s' and '%s'", result.Stdout, result.Stderr)
+	}
+	if result.ExitCode != 3 || result.Signal != "" {
+		t.Errorf("expected exit code 3 without signal, got %d (signal '%s')", result.ExitCode, result.Signal)
+	}
+	if result.Duration <= 0 {
+		t.Errorf("expected the duration to be recorded")
+	}
+	if err := result.Err(); err == nil || err.Error() != "exited with status 3: not found" {
+		t.Errorf("expected exit status error, got %v", err)
+	}
+
+	result, err = client.Exec(context.Background(), "killed")
+	if err != nil {
+		t.Fatalf("expected no error for a killed command, got %v", err)
+	}
+	if result.ExitCode != 137 || result.Signal != "KILL" {
+		t.Errorf("expected exit code 137 with signal KILL, got %d (signal '%s')", result.ExitCode, result.Signal)
+	}
+}
+
+func TestClient_ExecOutputLines(t *testing.T) {
+	server := newTestServer(t, func(cmd string, ch ssh.Channel) uint32 {
+		_, _ = ch.Write([]byte("first\r\nsec"))
+		_, _ = ch.Write([]byte("ond\nlast"))
+		_, _ = ch.Stderr().Write([]byte("warning\n"))
+		return 0
+	})
+	client := NewClient(&server.info, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer client.Close()
+
+	var stdout, stderr []string
+	result, err := client.Exec(context.Background(), "lines", WithOutputLines(func(stream string, line string) {
+		if stream == "stderr" {
+			stderr = append(stderr, line)
+			return
+		}
+		stdout = append(stdout, line)
+	}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !slices.Equal(stdout, []string{"first", "second", "last"}) {
+		t.Errorf("expected stdout lines [first second last], got %v", stdout)
+	}
+	if !slices.Equal(stderr, []string{"warning"}) {
+		t.Errorf("expected stderr lines [warning], got %v", stderr)
+	}
+	if result.Stdout != "first\r\nsecond\nlast" {
+		t.Errorf("expected the full stdout in the result, got %q", result.Stdout)
+	}
+}
+
+func TestClient_ExecPTY(t *testing.T) {
+	server := newTestServer(t, func(cmd string, ch ssh.Channel) uint32 {
+		_, _ = ch.Write([]byte("\x1b]0;title\x07\x1b[1;31mred\x1b[0m\r\nplain\r\n"))
+		return 0
+	})
+	client := NewClient(&server.info, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer client.Close()
+
+	var lines []string
+	result, err := client.Exec(context.Background(), "colors", WithPTY("", 120, 0), WithOutputLines(func(_ string, line string) {
+		lines = append(lines, line)
+	}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !result.PTY {
+		t.Errorf("expected the result to be from a pty")
+	}
+	if result.Stdout != "red\nplain\n" {
+		t.Errorf("expected output without escape sequences, got %q", result.Stdout)
+	}
+	if !slices.Equal(lines, []string{"red", "plain"}) {
+		t.Errorf("expected lines without escape sequences, got %q", lines)
+	}
+	if !slices.Equal(server.PTYs(), []string{"xterm 120x24"}) {
+		t.Errorf("expected pty xterm 120x24, got %v", server.PTYs())
+	}
+}
+
+func TestClient_ExecOutput(t *testing.T) {
+	server := newTestServer(t, func(cmd string, ch ssh.Channel) uint32 {
+		_, _ = ch.Write([]byte("\x1b[1;31mred\x1b[0m\r\nlast"))
+		_, _ = ch.Stderr().Write([]byte("warning\n"))
+		return 0
+	})
+	client := NewClient(&server.info, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer client.Close()
+
+	// the output is written as is and not kept in the result
+	var stdout, stderr bytes.Buffer
+	result, err := client.Exec(context.Background(), "colors", WithOutput(&stdout, &stderr))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Stdout != "" || result.Stderr != "" {
+		t.Errorf("expected no output in the result, got %q and %q", result.Stdout, result.Stderr)
+	}
+	if stdout.String() != "\x1b[1;31mred\x1b[0m\r\nlast" || stderr.String() != "warning\n" {
+		t.Errorf("expected the output in the writers, got %q and %q", stdout.String(), stderr.String())
+	}
+
+	// the output of a pty is cleaned up like the result
+	stdout.Reset()
+	stderr.Reset()
+	_, err = client.Exec(context.Background(), "colors", WithPTY("", 0, 0), WithOutput(&stdout, &stderr))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stdout.String() != "red\nlast" {
+		t.Errorf("expected output without escape sequences, got %q", stdout.String())
+	}
+}
+
+func TestClient_ExecStdin(t *testing.T) {
+	server := newTestServer(t, func(cmd string, ch ssh.Channel) uint32 {
+		// reads until the client closes the input
+		input, err := io.ReadAll(ch)
+		if err != nil {
+			return 1
+		}
+		_, _ = ch.Write(input)
+		return 0
+	})
+	client := NewClient(&server.info, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer client.Close()
+
+	result, err := client.Exec(context.Background(), "tee", WithStdin([]byte("line 1\nline 2\n")))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Stdout != "line 1\nline 2\n" {
+		t.Errorf("expected stdin to be written to the command, got %q", result.Stdout)
+	}
+}
+
+func TestClient_ExecEnv(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	info := server.info
+	info.Env = map[string]string{"LANG": "C", "APP_ENV": "staging"}
+	client := NewClient(&info, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer client.Close()
+
+	result, err := client.Exec(context.Background(), "make deploy", WithEnv(map[string]string{"APP_ENV": "production"}), WithDir("/srv/my app"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// the environment is set with the protocol, only the directory needs the shell
+	if result.Stdout != "cd '/srv/my app' && make deploy" {
+		t.Errorf("expected the command to change directory, got %q", result.Stdout)
+	}
+	if !slices.Equal(server.Envs(), []string{"APP_ENV=production", "LANG=C"}) {
+		t.Errorf("expected the environment of the command over the host, got %v", server.Envs())
+	}
+}
+
+func TestClient_ExecEnvRejected(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	server.RejectEnv()
+	client := NewClient(&server.info, WithHostKeyCallback(ssh.InsecureIgnoreHostKey()))
+	err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer client.Close()
+
+	result, err := client.Exec(context.Background(), "echo $GREETING", WithEnv(map[string]string{"GREETING": "it's me"}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Stdout != `export 'GREETING=it'\''s me' && echo $GREETING` {
+		t.Errorf("expected the environment to be set by the shell, got %q", result.Stdout)
+	}
+
+	_, err = client.Exec(context.Background(), "true", WithEnv(map[string]string{"NOT VALID": "1"}))
+	if err == nil {
+		t.Error("expected an invalid environment variable name to fail")
+	}
+}
+
+func TestWithShellPrefix(t *testing.T) {
+	env := map[string]string{"APP_ENV": "it's prod", "LANG": "C"}
+	tests := []struct {
+		shell    string
+		expected string
+	}{
+		{ShellPOSIX, `cd '/srv/app' && export 'APP_ENV=it'\''s prod' 'LANG=C' && make`},
+		{ShellPowerShell, `$env:APP_ENV = 'it''s prod'; $env:LANG = 'C'; Set-Location -LiteralPath '/srv/app'; make`},
+		{ShellCmd, `set "APP_ENV=it's prod" && set "LANG=C" && cd /d "/srv/app" && make`},
+	}
+	for _, tt := range tests {
+		cmd, err := withShellPrefix(tt.shell, "make", "/srv/app", env)
+		if err != nil {
+			t.Fatalf("expected no error for %s, got %v", tt.shell, err)
+		}
+		if cmd != tt.expected {
+			t.Errorf("expected %s for %s, got %s", tt.expected, tt.shell, cmd)
+		}
+	}
+	// the environment is set in the login shell, the command is not run by another shell
+	cmd, err := withShellPrefix(ShellPOSIX, "echo ${BASH_VERSION:-not bash}", "", env)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cmd != `export 'APP_ENV=it'\''s prod' 'LANG=C' && echo ${BASH_VERSION:-not bash}` {
+		t.Errorf("expected the environment to be exported in the shell, got %s", cmd)
+	}
+	_, err = withShellPrefix(ShellCmd, "make", "", map[string]string{"PATH": "%PATH%;C:\\bin"})
+	if err == nil {
+		t.Error("expected cmd.exe to reject a value with %")
+	}
+}
@@ -0,0 +1,391 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// OpenSSHConfig is a parsed OpenSSH client configuration file (~/.ssh/config).
+type OpenSSHConfig struct {
+	blocks []configBlock
+
+	// Warnings are the parts of the configuration that are ignored for every host.
+	Warnings []string
+}
+
+type configBlock struct {
+	patterns []string
+	options  []configOption
+}
+
+type configOption struct {
+	key   string // lower cased
+	name  string // as written in the file
+	value string
+}
+
+// ParseOpenSSHConfig parses the OpenSSH client configuration file at path, following any Include.
+func ParseOpenSSHConfig(path string) (*OpenSSHConfig, error) {
+	path = expandHome(path)
+	c := &OpenSSHConfig{
+		// options before the first Host apply to all hosts
+		blocks: []configBlock{{patterns: []string{"*"}}},
+	}
+	err := c.parseFile(path, filepath.Dir(path), 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Hosts returns the concrete host aliases (patterns without wildcards) in the order they are defined.
+func (c *OpenSSHConfig) Hosts() []string {
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, block := range c.blocks {
+		for _, pattern := range block.patterns {
+			if strings.ContainsAny(pattern, "*?!") || seen[pattern] {
+				continue
+			}
+			seen[pattern] = true
+			hosts = append(hosts, pattern)
+		}
+	}
+	return hosts
+}
+
+// Select returns the host aliases that match any of the patterns (all hosts when no patterns are provided).
+//
+// Hosts that are used as jump hosts by the selected hosts are also selected.
+func (c *OpenSSHConfig) Select(patterns []string) ([]string, error) {
+	var selected []string
+	seen := make(map[string]bool)
+	var add func(alias string)
+	add = func(alias string) {
+		if seen[alias] {
+			return
+		}
+		seen[alias] = true
+		for _, opt := range c.resolve(alias) {
+			if opt.key != "proxyjump" || opt.value == "none" {
+				continue
+			}
+			for _, jump := range c.jumpHosts(opt.value) {
+				if !strings.HasPrefix(jump, "ssh://") {
+					add(jump)
+				}
+			}
+		}
+		selected = append(selected, alias)
+	}
+	for _, alias := range c.Hosts() {
+		if len(patterns) == 0 || matchesPatterns(patterns, alias) {
+			add(alias)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no hosts in ssh config match: %s", strings.Join(patterns, ", "))
+	}
+	return selected, nil
+}
+
+// ClientInfo returns the client information for the host alias.
+//
+// Also returns the options that apply to the host but could not be mapped onto the client information.
+func (c *OpenSSHConfig) ClientInfo(alias string) (*ClientInfo, []string, error) {
+	options := c.resolve(alias)
+
+	info := &ClientInfo{
+		Name: alias,
+		Host: alias,
+		Port: "22",
+	}
+	var unmapped []string
+	var identityFiles []string
+	useAgent := true
+	for _, opt := range options {
+		switch opt.key {
+		case "hostname":
+			info.Host = expandTokens(opt.value, alias, "")
+		case "user":
+			info.User = opt.value
+		case "port":
+			info.Port = opt.value
+		case "identityfile":
+			identityFiles = append(identityFiles, opt.value)
+		case "certificatefile":
+			if info.CertPath == "" {
+				info.CertPath = opt.value
+			}
+		case "proxyjump":
+			if opt.value != "none" {
+				info.Jump = c.jumpHosts(opt.value)
+			}
+		case "connecttimeout":
+			info.ConnectTimeout = opt.value
+		case "setenv":
+			env := make(map[string]string)
+			for _, assignment := range strings.Fields(opt.value) {
+				name, value, ok := strings.Cut(assignment, "=")
+				if !ok || !envNamePattern.MatchString(name) {
+					unmapped = append(unmapped, fmt.Sprintf("%s %s", opt.name, assignment))
+					continue
+				}
+				env[name] = strings.Trim(value, `"`)
+			}
+			if len(env) > 0 {
+				info.Env = env
+			}
+		case "ciphers", "kexalgorithms", "macs", "hostkeyalgorithms":
+			if strings.HasPrefix(opt.value, "-") || strings.HasPrefix(opt.value, "^") {
+				unmapped = append(unmapped, fmt.Sprintf("%s %s (only lists and + are supported)", opt.name, opt.value))
+				continue
+			}
+			algorithms := strings.Split(opt.value, ",")
+			switch opt.key {
+			case "ciphers":
+				info.Ciphers = algorithms
+			case "kexalgorithms":
+				info.KeyExchanges = algorithms
+			case "macs":
+				info.MACs = algorithms
+			case "hostkeyalgorithms":
+				info.HostKeyAlgorithms = algorithms
+			}
+		case "forwardagent":
+			info.ForwardAgent = opt.value == "yes"
+		case "identityagent":
+			if opt.value == "none" {
+				useAgent = false
+			} else if opt.value != "SSH_AUTH_SOCK" {
+				unmapped = append(unmapped, fmt.Sprintf("IdentityAgent %s (only SSH_AUTH_SOCK is supported)", opt.value))
+			}
+		default:
+			unmapped = append(unmapped, fmt.Sprintf("%s %s", opt.name, opt.value))
+		}
+	}
+	if info.User == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, nil, fmt.Errorf("no User for %s and failed to get current user: %w", alias, err)
+		}
+		info.User = current.Username
+	}
+	for _, algorithm := range info.removeUnsupportedAlgorithms() {
+		unmapped = append(unmapped, fmt.Sprintf("algorithm %s (not supported)", algorithm))
+	}
+	if info.CertPath != "" {
+		info.CertPath = expandHome(expandTokens(info.CertPath, info.Host, info.User))
+	}
+	if len(identityFiles) > 0 {
+		info.KeyPath = expandHome(expandTokens(identityFiles[0], info.Host, info.User))
+		for _, identityFile := range identityFiles[1:] {
+			unmapped = append(unmapped, fmt.Sprintf("IdentityFile %s (only the first IdentityFile is used)", identityFile))
+		}
+	} else {
+		info.UseAgent = useAgent
+	}
+	return info, unmapped, nil
+}
+
+// resolve returns the options that apply to the host alias.
+//
+// Like OpenSSH the first obtained value for each option is used.
+func (c *OpenSSHConfig) resolve(alias string) []configOption {
+	var options []configOption
+	seen := make(map[string]bool)
+	for _, block := range c.blocks {
+		if !matchesPatterns(block.patterns, alias) {
+			continue
+		}
+		for _, opt := range block.options {
+			if seen[opt.key] && opt.key != "identityfile" {
+				continue
+			}
+			seen[opt.key] = true
+			options = append(options, opt)
+		}
+	}
+	return options
+}
+
+// jumpHosts converts the ProxyJump value into jump hosts.
+//
+// Hops that are hosts in the configuration are referenced by name, others become SSH connection strings.
+func (c *OpenSSHConfig) jumpHosts(value string) []string {
+	aliases := make(map[string]bool)
+	for _, alias := range c.Hosts() {
+		aliases[alias] = true
+	}
+	var jumps []string
+	for _, hop := range strings.Split(value, ",") {
+		hop = strings.TrimSpace(hop)
+		if hop == "" {
+			continue
+		}
+		if aliases[hop] || strings.HasPrefix(hop, "ssh://") {
+			jumps = append(jumps, hop)
+			continue
+		}
+		userPart, hostPart, ok := strings.Cut(hop, "@")
+		if !ok {
+			hostPart = hop
+			userPart = ""
+			if current, err := user.Current(); err == nil {
+				userPart = current.Username
+			}
+		}
+		if _, _, err := net.SplitHostPort(hostPart); err != nil {
+			hostPart = net.JoinHostPort(hostPart, "22")
+		}
+		jumps = append(jumps, fmt.Sprintf("ssh://%s@%s", userPart, hostPart))
+	}
+	return jumps
+}
+
+// parseFile parses the configuration file at path, its options apply to the block at index current until
+// the file opens a block. The blocks opened by the file end with it, so the including file continues
+// with its own block after an Include.
+func (c *OpenSSHConfig) parseFile(path string, baseDir string, current int, depth int) error {
+	if depth > 16 {
+		return fmt.Errorf("too many nested Include in %s", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open ssh config: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		name, value, ok := parseConfigLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		key := strings.ToLower(name)
+		switch key {
+		case "host":
+			c.blocks = append(c.blocks, configBlock{patterns: strings.Fields(value)})
+			current = len(c.blocks) - 1
+		case "match":
+			// match blocks cannot be evaluated, the block is kept without patterns so its options are ignored
+			c.Warnings = append(c.Warnings, fmt.Sprintf("%s:%d: Match %s is not supported and was ignored", path, lineNum, value))
+			c.blocks = append(c.blocks, configBlock{})
+			current = len(c.blocks) - 1
+		case "include":
+			for _, pattern := range strings.Fields(value) {
+				pattern = expandHome(pattern)
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(baseDir, pattern)
+				}
+				matches, err := filepath.Glob(pattern)
+				if err != nil {
+					return fmt.Errorf("%s:%d: invalid Include %s: %w", path, lineNum, pattern, err)
+				}
+				for _, match := range matches {
+					err = c.parseFile(match, baseDir, current, depth+1)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		default:
+			block := &c.blocks[current]
+			block.options = append(block.options, configOption{key: key, name: name, value: value})
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return fmt.Errorf("failed to read ssh config: %w", err)
+	}
+	return nil
+}
+
+// parseConfigLine parses a "Key value" or "Key=value" line.
+func parseConfigLine(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	idx := strings.IndexAny(line, " \t=")
+	if idx < 0 {
+		return line, "", true
+	}
+	key := line[:idx]
+	value := strings.TrimSpace(line[idx:])
+	value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	value = strings.Trim(value, `"`)
+	return key, value, true
+}
+
+// matchesPatterns returns true when the host matches one of the patterns and none of the negated patterns.
+func matchesPatterns(patterns []string, host string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		if negated, ok := strings.CutPrefix(pattern, "!"); ok {
+			if wildcardMatch(negated, host) {
+				return false
+			}
+			continue
+		}
+		if wildcardMatch(pattern, host) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// wildcardMatch matches the host against a pattern with the OpenSSH '*' and '?' wildcards.
+func wildcardMatch(pattern string, host string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := 0; i <= len(host); i++ {
+				if wildcardMatch(pattern[1:], host[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(host) == 0 {
+				return false
+			}
+		default:
+			if len(host) == 0 || pattern[0] != host[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		host = host[1:]
+	}
+	return len(host) == 0
+}
+
+// expandTokens expands the %h, %r, %d and %% tokens.
+func expandTokens(value string, host string, remoteUser string) string {
+	if !strings.Contains(value, "%") {
+		return value
+	}
+	home, _ := os.UserHomeDir()
+	replacer := strings.NewReplacer("%%", "%", "%h", host, "%r", remoteUser, "%d", home)
+	return replacer.Replace(value)
+}
+
+// expandHome expands a leading "~/" to the home directory.
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
@@ -0,0 +1,199 @@
+package ssh
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeSSHConfig(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("failed to write ssh config: %v", err)
+	}
+	return path
+}
+
+const testSSHConfig = `
+# global defaults
+ServerAliveInterval 60
+
+Host bastion
+    HostName bastion.example.com
+    User jump
+    IdentityFile /keys/bastion
+    ConnectTimeout 10
+
+Host web-*
+    User deploy
+    ProxyJump bastion
+
+Host web-1
+    HostName 10.0.0.1
+    Port 2222
+    User ignored
+
+Host db !db-old
+    HostName=db.example.com
+    User root
+    SetEnv APP_ENV=prod LANG="C.UTF-8"
+    KexAlgorithms +diffie-hellman-group1-sha1,sntrup761x25519-sha512@openssh.com
+    HostKeyAlgorithms ssh-rsa
+    ProxyJump admin@gateway.example.com:2200
+
+Match host legacy
+    User legacy
+
+Include conf.d/*
+`
+
+func TestParseOpenSSHConfig_Hosts(t *testing.T) {
+	dir := t.TempDir()
+	path := writeSSHConfig(t, dir, "config", testSSHConfig)
+	err := os.Mkdir(filepath.Join(dir, "conf.d"), 0700)
+	if err != nil {
+		t.Fatalf("failed to create conf.d: %v", err)
+	}
+	writeSSHConfig(t, filepath.Join(dir, "conf.d"), "extra", "Host extra\n  User extra\n")
+
+	cfg, err := ParseOpenSSHConfig(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expected := []string{"bastion", "web-1", "db", "extra"}
+	if !slices.Equal(cfg.Hosts(), expected) {
+		t.Errorf("expected hosts %v, got %v", expected, cfg.Hosts())
+	}
+	if len(cfg.Warnings) != 1 {
+		t.Errorf("expected 1 warning for Match, got %v", cfg.Warnings)
+	}
+}
+
+func TestOpenSSHConfig_ClientInfo(t *testing.T) {
+	path := writeSSHConfig(t, t.TempDir(), "config", testSSHConfig)
+	cfg, err := ParseOpenSSHConfig(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	info, unmapped, err := cfg.ClientInfo("web-1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if info.Host != "10.0.0.1" || info.Port != "2222" {
+		t.Errorf("expected 10.0.0.1:2222, got %s:%s", info.Host, info.Port)
+	}
+	if info.User != "deploy" {
+		t.Errorf("expected first obtained user 'deploy', got '%s'", info.User)
+	}
+	if !slices.Equal(info.Jump, []string{"bastion"}) {
+		t.Errorf("expected jump [bastion], got %v", info.Jump)
+	}
+	if !info.UseAgent {
+		t.Errorf("expected host without IdentityFile to use the ssh-agent")
+	}
+	if !slices.Equal(unmapped, []string{"ServerAliveInterval 60"}) {
+		t.Errorf("expected unmapped [ServerAliveInterval 60], got %v", unmapped)
+	}
+
+	info, _, err = cfg.ClientInfo("bastion")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if info.KeyPath != "/keys/bastion" || info.UseAgent {
+		t.Errorf("expected key /keys/bastion without agent, got %s (agent %v)", info.KeyPath, info.UseAgent)
+	}
+	if info.ConnectTimeout != "10" {
+		t.Errorf("expected connect timeout 10, got '%s'", info.ConnectTimeout)
+	}
+
+	info, unmapped, err = cfg.ClientInfo("db")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if info.Host != "db.example.com" {
+		t.Errorf("expected host db.example.com, got %s", info.Host)
+	}
+	if !slices.Equal(info.Jump, []string{"ssh://admin@gateway.example.com:2200"}) {
+		t.Errorf("expected jump connection string, got %v", info.Jump)
+	}
+	if !maps.Equal(info.Env, map[string]string{"APP_ENV": "prod", "LANG": "C.UTF-8"}) {
+		t.Errorf("expected env from SetEnv, got %v", info.Env)
+	}
+	if !slices.Equal(info.KeyExchanges, []string{"+diffie-hellman-group1-sha1"}) || !slices.Equal(info.HostKeyAlgorithms, []string{"ssh-rsa"}) {
+		t.Errorf("expected the legacy algorithms, got %v and %v", info.KeyExchanges, info.HostKeyAlgorithms)
+	}
+	if !slices.Equal(unmapped, []string{"ServerAliveInterval 60", "algorithm sntrup761x25519-sha512@openssh.com (not supported)"}) {
+		t.Errorf("expected the unsupported key exchange to be unmapped, got %v", unmapped)
+	}
+}
+
+func TestOpenSSHConfig_ClientInfoIncludeInHost(t *testing.T) {
+	dir := t.TempDir()
+	writeSSHConfig(t, dir, "common", "User common\n\nHost included\n  HostName included.example.com\n")
+	path := writeSSHConfig(t, dir, "config", "Host app\n  Include common\n  HostName app.example.com\n  Port 2222\n")
+	cfg, err := ParseOpenSSHConfig(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// the options after the Include belong to the Host block it is in, not the block the included file opened
+	info, _, err := cfg.ClientInfo("app")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if info.Host != "app.example.com" || info.Port != "2222" || info.User != "common" {
+		t.Errorf("expected common@app.example.com:2222, got %s@%s:%s", info.User, info.Host, info.Port)
+	}
+	info, _, err = cfg.ClientInfo("included")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if info.Host != "included.example.com" || info.Port == "2222" {
+		t.Errorf("expected included.example.com without the options of app, got %s:%s", info.Host, info.Port)
+	}
+}
+
+func TestOpenSSHConfig_Select(t *testing.T) {
+	path := writeSSHConfig(t, t.TempDir(), "config", testSSHConfig)
+	cfg, err := ParseOpenSSHConfig(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	selected, err := cfg.Select([]string{"web-*"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !slices.Equal(selected, []string{"bastion", "web-1"}) {
+		t.Errorf("expected jump host to be selected with web-1, got %v", selected)
+	}
+
+	_, err = cfg.Select([]string{"missing"})
+	if err == nil {
+		t.Errorf("expected error when no hosts match, got nil")
+	}
+}
+
+func TestMatchesPatterns(t *testing.T) {
+	cases := []struct {
+		patterns []string
+		host     string
+		expected bool
+	}{
+		{[]string{"*"}, "anything", true},
+		{[]string{"web-?"}, "web-1", true},
+		{[]string{"web-?"}, "web-10", false},
+		{[]string{"*.example.com", "!db.example.com"}, "web.example.com", true},
+		{[]string{"*.example.com", "!db.example.com"}, "db.example.com", false},
+		{[]string{"!db"}, "web", false},
+	}
+	for _, c := range cases {
+		if got := matchesPatterns(c.patterns, c.host); got != c.expected {
+			t.Errorf("matchesPatterns(%v, %s): expected %v, got %v", c.patterns, c.host, c.expected, got)
+		}
+	}
+}
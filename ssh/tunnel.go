@@ -0,0 +1,366 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTunnelNotFound returned when there is no open tunnel with the ID.
+var ErrTunnelNotFound = errors.New("tunnel not found")
+
+const (
+	// ForwardLocal listens locally and forwards the connections to the target through the SSH server (ssh -L).
+	ForwardLocal = "local"
+	// ForwardRemote listens on the SSH server and forwards the connections to the local target (ssh -R).
+	ForwardRemote = "remote"
+)
+
+// ForwardSpec describes a port forward.
+type ForwardSpec struct {
+	// Direction is ForwardLocal or ForwardRemote.
+	Direction string
+	// ListenAddress is the address to listen on, only a port listens on the loopback interface.
+	ListenAddress string
+	// TargetAddress is the address the connections are forwarded to.
+	TargetAddress string
+}
+
+// TunnelInfo is the state of a tunnel.
+type TunnelInfo struct {
+	ID                string    `json:"id"`
+	Host              string    `json:"host"`
+	Direction         string    `json:"direction"`
+	ListenAddress     string    `json:"listen_address"`
+	TargetAddress     string    `json:"target_address"`
+	CreatedAt         time.Time `json:"created_at"`
+	Connections       int64     `json:"connections"`
+	ActiveConnections int64     `json:"active_connections"`
+	BytesToTarget     int64     `json:"bytes_to_target"`
+	BytesFromTarget   int64     `json:"bytes_from_target"`
+	LastError         string    `json:"last_error,omitempty"`
+	Closed            bool      `json:"closed,omitempty"`
+	// Failed is set when the tunnel was closed because the connection to the SSH server was lost.
+	Failed bool `json:"failed,omitempty"`
+}
+
+// tunnel is an open port forward through a connection from the manager.
+type tunnel struct {
+	id        string
+	host      string
+	spec      ForwardSpec
+	createdAt time.Time
+
+	client   *Client
+	release  func()
+	listener net.Listener
+
+	connections     atomic.Int64
+	active          atomic.Int64
+	bytesToTarget   atomic.Int64
+	bytesFromTarget atomic.Int64
+
+	mx        sync.Mutex
+	conns     map[net.Conn]struct{}
+	lastError string
+	closed    bool
+	failed    bool
+	done      chan struct{}
+
+	wg sync.WaitGroup
+}
+
+// Forward opens the port forward through the connection to the SSH server.
+//
+// The tunnel takes over the connection, release is called once the tunnel is closed. The tunnel stays
+// open until it is closed with CloseTunnel, the connection to the SSH server is lost or the manager is closed.
+// A tunnel whose connection is lost is closed and marked as failed, it is still listed until it is closed
+// with CloseTunnel so the failure is seen.
+func (m *Manager) Forward(client *Client, release func(), spec ForwardSpec) (*TunnelInfo, error) {
+	if client.client == nil {
+		release()
+		return nil, ErrNotConnected
+	}
+	listenAddress := spec.ListenAddress
+	if !strings.Contains(listenAddress, ":") {
+		listenAddress = net.JoinHostPort("127.0.0.1", listenAddress)
+	}
+
+	var listener net.Listener
+	var err error
+	switch spec.Direction {
+	case ForwardLocal:
+		listener, err = net.Listen("tcp", listenAddress)
+	case ForwardRemote:
+		listener, err = client.client.Listen("tcp", listenAddress)
+	default:
+		err = fmt.Errorf("unknown forward direction %q", spec.Direction)
+	}
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("failed to listen on %s: %w", listenAddress, err)
+	}
+	// the actual address when listening on port 0
+	spec.ListenAddress = listener.Addr().String()
+
+	m.mx.Lock()
+	if m.closed {
+		m.mx.Unlock()
+		_ = listener.Close()
+		release()
+		return nil, ErrManagerClosed
+	}
+	m.tunnelID++
+	t := &tunnel{
+		id:        fmt.Sprintf("tunnel-%d", m.tunnelID),
+		host:      client.info.Name,
+		spec:      spec,
+		createdAt: time.Now(),
+		client:    client,
+		release:   release,
+		listener:  listener,
+		conns:     make(map[net.Conn]struct{}),
+		done:      make(chan struct{}),
+	}
+	m.tunnels[t.id] = t
+	m.mx.Unlock()
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		t.serve()
+	}()
+	go t.watch()
+	info := t.info()
+	return &info, nil
+}
+
+// Tunnels returns the state of the open tunnels and of the failed tunnels that are not closed yet.
+func (m *Manager) Tunnels() []TunnelInfo {
+	m.mx.Lock()
+	tunnels := make([]*tunnel, 0, len(m.tunnels))
+	for _, t := range m.tunnels {
+		tunnels = append(tunnels, t)
+	}
+	m.mx.Unlock()
+
+	infos := make([]TunnelInfo, 0, len(tunnels))
+	for _, t := range tunnels {
+		infos = append(infos, t.info())
+	}
+	slices.SortFunc(infos, func(a, b TunnelInfo) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return infos
+}
+
+// CloseTunnel closes the tunnel and returns its final state.
+func (m *Manager) CloseTunnel(id string) (*TunnelInfo, error) {
+	m.mx.Lock()
+	t, ok := m.tunnels[id]
+	delete(m.tunnels, id)
+	m.mx.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTunnelNotFound, id)
+	}
+	t.close()
+	info := t.info()
+	return &info, nil
+}
+
+// closeTunnels closes all the tunnels.
+func (m *Manager) closeTunnels() {
+	m.mx.Lock()
+	tunnels := make([]*tunnel, 0, len(m.tunnels))
+	for id, t := range m.tunnels {
+		tunnels = append(tunnels, t)
+		delete(m.tunnels, id)
+	}
+	m.mx.Unlock()
+	for _, t := range tunnels {
+		t.close()
+	}
+}
+
+// failTunnels closes the tunnels through the client as the connection to the SSH server was lost.
+func (m *Manager) failTunnels(client *Client, err error) {
+	m.mx.Lock()
+	var tunnels []*tunnel
+	for _, t := range m.tunnels {
+		if t.client == client {
+			tunnels = append(tunnels, t)
+		}
+	}
+	m.mx.Unlock()
+	for _, t := range tunnels {
+		t.fail(err)
+	}
+}
+
+// watch fails the tunnel when the connection to the SSH server is closed before the tunnel.
+func (t *tunnel) watch() {
+	lost := make(chan error, 1)
+	go func() {
+		lost <- t.client.client.Wait()
+	}()
+	select {
+	case err := <-lost:
+		if err == nil {
+			err = errors.New("connection closed")
+		}
+		t.fail(err)
+	case <-t.done:
+	}
+}
+
+// fail closes the tunnel and marks it as failed as the connection to the SSH server was lost.
+func (t *tunnel) fail(err error) {
+	t.mx.Lock()
+	if t.closed {
+		t.mx.Unlock()
+		return
+	}
+	t.failed = true
+	t.lastError = fmt.Sprintf("connection to the SSH server was lost: %s", err)
+	t.mx.Unlock()
+	t.close()
+}
+
+// serve accepts connections until the listener is closed.
+func (t *tunnel) serve() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			t.mx.Lock()
+			if !t.closed {
+				// the connection to the SSH server was lost
+				t.lastError = err.Error()
+			}
+			t.mx.Unlock()
+			return
+		}
+		if !t.track(conn) {
+			_ = conn.Close()
+			return
+		}
+		t.connections.Add(1)
+		t.active.Add(1)
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			defer t.active.Add(-1)
+			defer t.untrack(conn)
+			t.forward(conn)
+		}()
+	}
+}
+
+// forward connects to the target and copies the data in both directions.
+func (t *tunnel) forward(conn net.Conn) {
+	defer conn.Close()
+
+	var target net.Conn
+	var err error
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDialTimeout)
+	defer cancel()
+	if t.spec.Direction == ForwardLocal {
+		target, err = t.client.client.DialContext(ctx, "tcp", t.spec.TargetAddress)
+	} else {
+		var dialer net.Dialer
+		target, err = dialer.DialContext(ctx, "tcp", t.spec.TargetAddress)
+	}
+	if err != nil {
+		t.mx.Lock()
+		t.lastError = fmt.Sprintf("failed to connect to %s: %s", t.spec.TargetAddress, err)
+		t.mx.Unlock()
+		return
+	}
+	if !t.track(target) {
+		_ = target.Close()
+		return
+	}
+	defer t.untrack(target)
+	defer target.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		n, _ := io.Copy(target, conn)
+		t.bytesToTarget.Add(n)
+		closeWrite(target)
+	}()
+	n, _ := io.Copy(conn, target)
+	t.bytesFromTarget.Add(n)
+	closeWrite(conn)
+	<-done
+}
+
+// track records the connection so it is closed with the tunnel, false when the tunnel is already closed.
+func (t *tunnel) track(conn net.Conn) bool {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if t.closed {
+		return false
+	}
+	t.conns[conn] = struct{}{}
+	return true
+}
+
+func (t *tunnel) untrack(conn net.Conn) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	delete(t.conns, conn)
+}
+
+// close stops listening, closes the forwarded connections and releases the connection to the SSH server.
+func (t *tunnel) close() {
+	t.mx.Lock()
+	if t.closed {
+		t.mx.Unlock()
+		return
+	}
+	t.closed = true
+	close(t.done)
+	_ = t.listener.Close()
+	for conn := range t.conns {
+		_ = conn.Close()
+	}
+	t.mx.Unlock()
+
+	t.wg.Wait()
+	t.release()
+}
+
+func (t *tunnel) info() TunnelInfo {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return TunnelInfo{
+		ID:                t.id,
+		Host:              t.host,
+		Direction:         t.spec.Direction,
+		ListenAddress:     t.spec.ListenAddress,
+		TargetAddress:     t.spec.TargetAddress,
+		CreatedAt:         t.createdAt,
+		Connections:       t.connections.Load(),
+		ActiveConnections: t.active.Load(),
+		BytesToTarget:     t.bytesToTarget.Load(),
+		BytesFromTarget:   t.bytesFromTarget.Load(),
+		LastError:         t.lastError,
+		Closed:            t.closed,
+		Failed:            t.failed,
+	}
+}
+
+// closeWrite signals the end of the data when the connection supports half-close.
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
+	}
+}
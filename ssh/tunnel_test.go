@@ -0,0 +1,171 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// newEchoListener starts a TCP server that echoes every line back.
+func newEchoListener(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// roundTrip sends a line through the address and returns the echoed line.
+func roundTrip(t *testing.T, addr string, line string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to dial %s: %v", addr, err)
+	}
+	defer conn.Close()
+	_, err = io.WriteString(conn, line+"\n")
+	if err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	got, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	return got[:len(got)-1]
+}
+
+func TestManager_ForwardLocal(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	target := newEchoListener(t)
+	m := NewManager()
+	defer m.Close()
+
+	client, release, err := m.Get(context.Background(), server.info, nil, insecureOption())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	tunnel, err := m.Forward(client, release, ForwardSpec{Direction: ForwardLocal, ListenAddress: "0", TargetAddress: target})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := roundTrip(t, tunnel.ListenAddress, "hello"); got != "hello" {
+		t.Errorf("expected hello through the tunnel, got %q", got)
+	}
+
+	tunnels := m.Tunnels()
+	if len(tunnels) != 1 || tunnels[0].ID != tunnel.ID || tunnels[0].Host != server.info.Name {
+		t.Fatalf("expected the tunnel to be listed, got %+v", tunnels)
+	}
+	closed, err := m.CloseTunnel(tunnel.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if closed.Connections != 1 || closed.BytesToTarget != 6 || closed.BytesFromTarget != 6 {
+		t.Errorf("expected 1 connection with 6 bytes each way, got %+v", closed)
+	}
+	if _, err := net.Dial("tcp", tunnel.ListenAddress); err == nil {
+		t.Errorf("expected the listener to be closed")
+	}
+	if len(m.Tunnels()) != 0 {
+		t.Errorf("expected no tunnels after close")
+	}
+	_, err = m.CloseTunnel(tunnel.ID)
+	if !errors.Is(err, ErrTunnelNotFound) {
+		t.Errorf("expected tunnel not found, got %v", err)
+	}
+}
+
+func TestManager_ForwardRemote(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	target := newEchoListener(t)
+	m := NewManager()
+
+	client, release, err := m.Get(context.Background(), server.info, nil, insecureOption())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	tunnel, err := m.Forward(client, release, ForwardSpec{Direction: ForwardRemote, ListenAddress: "127.0.0.1:0", TargetAddress: target})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// the test server listens on the same machine
+	if got := roundTrip(t, tunnel.ListenAddress, "remote"); got != "remote" {
+		t.Errorf("expected remote through the tunnel, got %q", got)
+	}
+
+	// closing the manager tears down the tunnels
+	err = m.Close()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(m.Tunnels()) != 0 {
+		t.Errorf("expected no tunnels after the manager is closed")
+	}
+	if m.Len() != 0 {
+		t.Errorf("expected no connections after the manager is closed, got %d", m.Len())
+	}
+}
+
+func TestManager_ForwardConnectionLost(t *testing.T) {
+	server := newTestServer(t, echoHandler)
+	target := newEchoListener(t)
+	m := NewManager()
+	defer m.Close()
+
+	client, release, err := m.Get(context.Background(), server.info, nil, insecureOption())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	tunnel, err := m.Forward(client, release, ForwardSpec{Direction: ForwardLocal, ListenAddress: "0", TargetAddress: target})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// the tunnel stops listening and is listed as failed once the connection is lost
+	server.DropConnections()
+	deadline := time.Now().Add(5 * time.Second)
+	var tunnels []TunnelInfo
+	for time.Now().Before(deadline) {
+		tunnels = m.Tunnels()
+		if len(tunnels) == 1 && tunnels[0].Failed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(tunnels) != 1 || !tunnels[0].Failed || !tunnels[0].Closed || tunnels[0].LastError == "" {
+		t.Fatalf("expected the tunnel to fail, got %+v", tunnels)
+	}
+	if _, err := net.Dial("tcp", tunnel.ListenAddress); err == nil {
+		t.Errorf("expected the listener to be closed")
+	}
+
+	closed, err := m.CloseTunnel(tunnel.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !closed.Failed {
+		t.Errorf("expected the closed tunnel to still be failed, got %+v", closed)
+	}
+	if len(m.Tunnels()) != 0 {
+		t.Errorf("expected no tunnels after close")
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/blakerouse/sshai/ssh"
 	"gopkg.in/yaml.v3"
@@ -11,16 +14,25 @@ import (
 
 // Engine is the storage engine for SSH connections.
 type Engine struct {
+	mx    sync.Mutex
 	hosts map[string]ssh.ClientInfo
 
 	// path to store the state
 	path string
+
+	// known hosts used to verify the host keys
+	knownHosts *ssh.KnownHosts
+
+	// time the stored outputs of commands are kept
+	outputRetention time.Duration
 }
 
 // NewEngine creates a new storage Engine instance.
 func NewEngine(path string) (*Engine, error) {
 	e := &Engine{
-		path: path,
+		path:            path,
+		knownHosts:      ssh.NewKnownHosts(filepath.Join(filepath.Dir(path), "known_hosts")),
+		outputRetention: DefaultOutputRetention,
 	}
 	err := e.load()
 	if err != nil {
@@ -29,8 +41,27 @@ func NewEngine(path string) (*Engine, error) {
 	return e, nil
 }
 
+// Dir returns the directory of the storage file, other state (e.g. downloads) is kept in it.
+func (e *Engine) Dir() string {
+	return filepath.Dir(e.path)
+}
+
+// KnownHosts returns the known hosts used to verify the host keys.
+//
+// By default this is the known_hosts file next to the storage file.
+func (e *Engine) KnownHosts() *ssh.KnownHosts {
+	return e.knownHosts
+}
+
+// SetKnownHostsPath changes the known_hosts file used to verify the host keys.
+func (e *Engine) SetKnownHostsPath(path string) {
+	e.knownHosts = ssh.NewKnownHosts(path)
+}
+
 // Get retrieves the SSH client information for a host.
 func (e *Engine) Get(host string) (ssh.ClientInfo, bool) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
 	info, ok := e.hosts[host]
 	if !ok {
 		return ssh.ClientInfo{}, false
@@ -40,18 +71,39 @@ func (e *Engine) Get(host string) (ssh.ClientInfo, bool) {
 
 // Set saves the SSH client information for a host.
 func (e *Engine) Set(info ssh.ClientInfo) error {
+	e.mx.Lock()
+	defer e.mx.Unlock()
 	e.hosts[info.Name] = info
 	return e.save()
 }
 
 // Delete removes the SSH client information for a host.
 func (e *Engine) Delete(host string) error {
+	e.mx.Lock()
+	defer e.mx.Unlock()
 	delete(e.hosts, host)
 	return e.save()
 }
 
+// SetAlgorithms saves the algorithms negotiated on the last connection to the host.
+//
+// Nothing is saved when the host is not stored or the algorithms did not change.
+func (e *Engine) SetAlgorithms(host string, algorithms *ssh.NegotiatedAlgorithms) error {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	info, ok := e.hosts[host]
+	if !ok || algorithms == nil || (info.Algorithms != nil && *info.Algorithms == *algorithms) {
+		return nil
+	}
+	info.Algorithms = algorithms
+	e.hosts[host] = info
+	return e.save()
+}
+
 // List retrieves the names of all hosts.
 func (e *Engine) List() ([]ssh.ClientInfo, error) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
 	var hosts []ssh.ClientInfo
 	for _, info := range e.hosts {
 		hosts = append(hosts, info)
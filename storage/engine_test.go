@@ -86,6 +86,32 @@ func TestEngine_Delete(t *testing.T) {
 	require.False(t, ok)
 }
 
+func TestEngine_SetAlgorithms(t *testing.T) {
+	path := tempFilePath(t)
+	e, err := NewEngine(path)
+	require.NoError(t, err)
+
+	algorithms := &ssh.NegotiatedAlgorithms{
+		KeyExchange: "curve25519-sha256",
+		HostKey:     "ssh-ed25519",
+		Cipher:      "aes128-gcm@openssh.com",
+	}
+	// hosts that are not stored are ignored
+	require.NoError(t, e.SetAlgorithms("host1", algorithms))
+	_, ok := e.Get("host1")
+	require.False(t, ok)
+
+	require.NoError(t, e.Set(dummyClientInfo("host1")))
+	require.NoError(t, e.SetAlgorithms("host1", algorithms))
+
+	// the algorithms are persisted with the host
+	e, err = NewEngine(path)
+	require.NoError(t, err)
+	got, ok := e.Get("host1")
+	require.True(t, ok)
+	require.Equal(t, algorithms, got.Algorithms)
+}
+
 func TestEngine_List(t *testing.T) {
 	path := tempFilePath(t)
 	e, err := NewEngine(path)
@@ -123,3 +149,21 @@ func TestEngine_save_Error(t *testing.T) {
 	err := e.save()
 	require.Error(t, err)
 }
+
+func TestEngine_Dir(t *testing.T) {
+	path := tempFilePath(t)
+	e, err := NewEngine(path)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Dir(path), e.Dir())
+}
+
+func TestEngine_KnownHosts(t *testing.T) {
+	path := tempFilePath(t)
+	e, err := NewEngine(path)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(filepath.Dir(path), "known_hosts"), e.KnownHosts().Path())
+
+	other := filepath.Join(t.TempDir(), "other_known_hosts")
+	e.SetKnownHostsPath(other)
+	require.Equal(t, other, e.KnownHosts().Path())
+}
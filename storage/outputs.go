@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// ErrOutputNotFound returned when there is no stored output with the ID.
+var ErrOutputNotFound = errors.New("output not found")
+
+// DefaultOutputRetention is the default time the stored outputs are kept.
+const DefaultOutputRetention = 24 * time.Hour
+
+// outputIDTime is the layout of the time the output was stored at the start of its ID.
+const outputIDTime = "20060102T150405"
+
+// outputIDPattern matches the IDs of stored outputs, which are used as directory names.
+var outputIDPattern = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}-[0-9a-f]{8}$`)
+
+// outputStreams are the streams that are stored for an output.
+var outputStreams = []string{"stdout", "stderr"}
+
+// OutputPage is part of a stored output.
+type OutputPage struct {
+	Data []byte
+	// Offset is the offset of the data in the stream.
+	Offset int64
+	// Size is the size of the whole stream.
+	Size int64
+}
+
+// Output is the full output of a command that is stored in the outputs directory.
+type Output struct {
+	// ID is the ID that the output is read back with.
+	ID string
+
+	dir string
+}
+
+// SetOutputRetention sets the time the stored outputs are kept, zero keeps them forever.
+func (e *Engine) SetOutputRetention(retention time.Duration) {
+	e.outputRetention = retention
+}
+
+// NewOutput creates a new output in the outputs directory next to the storage file.
+//
+// The outputs that are older than the output retention are removed first.
+func (e *Engine) NewOutput() (*Output, error) {
+	// best effort, the outputs that are not removed are removed with the next output
+	_ = e.PruneOutputs()
+
+	suffix := make([]byte, 4)
+	_, err := rand.Read(suffix)
+	if err != nil {
+		return nil, err
+	}
+	id := fmt.Sprintf("%s-%s", time.Now().UTC().Format(outputIDTime), hex.EncodeToString(suffix))
+	dir := filepath.Join(e.Dir(), "outputs", id)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	return &Output{ID: id, dir: dir}, nil
+}
+
+// Create creates the file the stream (stdout or stderr) of the output is written to.
+func (o *Output) Create(stream string) (*os.File, error) {
+	if !slices.Contains(outputStreams, stream) {
+		return nil, fmt.Errorf("invalid stream %q: must be stdout or stderr", stream)
+	}
+	f, err := os.OpenFile(filepath.Join(o.dir, stream), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", stream, err)
+	}
+	return f, nil
+}
+
+// Remove removes the output.
+func (o *Output) Remove() error {
+	return os.RemoveAll(o.dir)
+}
+
+// PruneOutputs removes the stored outputs that are older than the output retention.
+//
+// The age of an output is from the time in its ID, which is when it was created.
+func (e *Engine) PruneOutputs() error {
+	if e.outputRetention <= 0 {
+		return nil
+	}
+	dir := filepath.Join(e.Dir(), "outputs")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read outputs directory: %w", err)
+	}
+	var errs []error
+	for _, entry := range entries {
+		if !entry.IsDir() || !outputIDPattern.MatchString(entry.Name()) {
+			continue
+		}
+		createdAt, err := time.Parse(outputIDTime, entry.Name()[:len(outputIDTime)])
+		if err != nil || time.Since(createdAt) < e.outputRetention {
+			continue
+		}
+		err = os.RemoveAll(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ReadOutput reads at most limit bytes of the stream (stdout or stderr) of the stored output from the offset.
+func (e *Engine) ReadOutput(id string, stream string, offset int64, limit int) (*OutputPage, error) {
+	if !outputIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("%w: %s", ErrOutputNotFound, id)
+	}
+	if !slices.Contains(outputStreams, stream) {
+		return nil, fmt.Errorf("invalid stream %q: must be stdout or stderr", stream)
+	}
+	if offset < 0 || limit <= 0 {
+		return nil, errors.New("offset cannot be negative and limit must be positive")
+	}
+	f, err := os.Open(filepath.Join(e.Dir(), "outputs", id, stream))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrOutputNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to open output: %w", err)
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat output: %w", err)
+	}
+	page := &OutputPage{
+		Offset: min(offset, stat.Size()),
+		Size:   stat.Size(),
+	}
+	page.Data = make([]byte, min(int64(limit), page.Size-page.Offset))
+	_, err = f.ReadAt(page.Data, page.Offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read output: %w", err)
+	}
+	return page, nil
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_SaveAndReadOutput(t *testing.T) {
+	e, err := NewEngine(tempFilePath(t))
+	require.NoError(t, err)
+
+	output, err := e.NewOutput()
+	require.NoError(t, err)
+	id := output.ID
+	require.DirExists(t, filepath.Join(e.Dir(), "outputs", id))
+	for stream, data := range map[string]string{"stdout": "line 1\nline 2\n", "stderr": "warning\n"} {
+		f, err := output.Create(stream)
+		require.NoError(t, err)
+		_, err = f.WriteString(data)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+	_, err = output.Create("stdin")
+	require.Error(t, err)
+
+	page, err := e.ReadOutput(id, "stdout", 0, 7)
+	require.NoError(t, err)
+	require.Equal(t, "line 1\n", string(page.Data))
+	require.Equal(t, int64(0), page.Offset)
+	require.Equal(t, int64(14), page.Size)
+
+	page, err = e.ReadOutput(id, "stdout", 7, 100)
+	require.NoError(t, err)
+	require.Equal(t, "line 2\n", string(page.Data))
+
+	page, err = e.ReadOutput(id, "stderr", 100, 100)
+	require.NoError(t, err)
+	require.Empty(t, page.Data)
+	require.Equal(t, int64(8), page.Offset)
+
+	_, err = e.ReadOutput(id, "stdin", 0, 100)
+	require.Error(t, err)
+}
+
+func TestEngine_ReadOutput_NotFound(t *testing.T) {
+	e, err := NewEngine(tempFilePath(t))
+	require.NoError(t, err)
+
+	_, err = e.ReadOutput("20240101T000000-00000000", "stdout", 0, 100)
+	require.ErrorIs(t, err, ErrOutputNotFound)
+
+	// IDs cannot escape the outputs directory
+	_, err = e.ReadOutput("../engine_test.yaml", "stdout", 0, 100)
+	require.ErrorIs(t, err, ErrOutputNotFound)
+}
+
+func TestEngine_PruneOutputs(t *testing.T) {
+	e, err := NewEngine(tempFilePath(t))
+	require.NoError(t, err)
+
+	old := filepath.Join(e.Dir(), "outputs", time.Now().Add(-48*time.Hour).UTC().Format(outputIDTime)+"-00000000")
+	require.NoError(t, os.MkdirAll(old, 0700))
+	// other entries in the outputs directory are never removed
+	other := filepath.Join(e.Dir(), "outputs", "notes")
+	require.NoError(t, os.MkdirAll(other, 0700))
+
+	// old outputs are removed when a new output is created
+	output, err := e.NewOutput()
+	require.NoError(t, err)
+	require.NoDirExists(t, old)
+	require.DirExists(t, other)
+	require.DirExists(t, filepath.Join(e.Dir(), "outputs", output.ID))
+
+	// zero keeps the outputs forever
+	require.NoError(t, os.MkdirAll(old, 0700))
+	e.SetOutputRetention(0)
+	require.NoError(t, e.PruneOutputs())
+	require.DirExists(t, old)
+
+	require.NoError(t, output.Remove())
+	require.NoDirExists(t, filepath.Join(e.Dir(), "outputs", output.ID))
+}
@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&AcceptHostKey{})
+}
+
+// AcceptHostKey is a tool that shows and re-accepts the host key of a host.
+type AcceptHostKey struct{}
+
+// hostKeyStatus is the status of the host key presented by a host.
+type hostKeyStatus struct {
+	ssh.HostKey
+
+	Status            string   `json:"status"`
+	KnownFingerprints []string `json:"known_fingerprints,omitempty"`
+}
+
+// Definition returns the mcp.Tool definition.
+func (c *AcceptHostKey) Definition() mcp.Tool {
+	return mcp.NewTool("accept_host_key",
+		mcp.WithDescription("Shows the host key presented by a host compared to the known host key. "+
+			"When a fingerprint is provided the presented host key is accepted and replaces the known host key. "+
+			"Only accept a changed host key after confirming with the user that the host was legitimately rebuilt."),
+		mcp.WithString("name_of_host",
+			mcp.Required(),
+			mcp.Description("Name of the host"),
+		),
+		mcp.WithString("fingerprint",
+			mcp.Description("SHA256 fingerprint of the presented host key to accept"),
+		),
+	)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *AcceptHostKey) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sshNameOfHost, err := request.RequireString("name_of_host")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		fingerprint := request.GetString("fingerprint", "")
+
+		host, ok := storageEngine.Get(sshNameOfHost)
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("host %s not found", sshNameOfHost)), nil
+		}
+
+		sshClient, release, err := newClient(ctx, storageEngine, manager, &host, false)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		defer release()
+		hostKey, err := sshClient.ScanHostKey(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		status := hostKeyStatus{HostKey: *hostKey}
+
+		knownHosts := storageEngine.KnownHosts()
+		var unknownErr *ssh.UnknownHostKeyError
+		var mismatchErr *ssh.HostKeyMismatchError
+		err = knownHosts.Verify(hostKey)
+		switch {
+		case err == nil:
+			status.Status = "trusted"
+		case errors.As(err, &unknownErr):
+			status.Status = "unknown"
+		case errors.As(err, &mismatchErr):
+			status.Status = "changed"
+			status.KnownFingerprints = mismatchErr.Known
+		default:
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if fingerprint == "" || status.Status == "trusted" {
+			return mcp.NewToolResultStructuredOnly(status), nil
+		}
+		if fingerprint != hostKey.Fingerprint {
+			return mcp.NewToolResultError(fmt.Sprintf(
+				"presented host key %s does not match the fingerprint to accept %s", hostKey.Fingerprint, fingerprint)), nil
+		}
+		err = knownHosts.Accept(hostKey)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Errorf("failed to accept host key: %w", err).Error()), nil
+		}
+		status.Status = "accepted"
+		return mcp.NewToolResultStructuredOnly(status), nil
+	}
+}
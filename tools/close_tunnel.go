@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&CloseTunnel{})
+}
+
+// CloseTunnel is a tool that closes an open port forward.
+type CloseTunnel struct{}
+
+// Definition returns the mcp.Tool definition.
+func (c *CloseTunnel) Definition() mcp.Tool {
+	return mcp.NewTool("close_tunnel",
+		mcp.WithDescription("Closes an open port forward and its connections."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("ID of the tunnel"),
+		),
+	)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *CloseTunnel) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, err := request.RequireString("id")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		tunnel, err := manager.CloseTunnel(id)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultStructured(tunnel, fmt.Sprintf("closed %s", tunnel.ID)), nil
+	}
+}
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&CommandOutput{})
+}
+
+// commandOutputPage is part of the stored output of a command.
+type commandOutputPage struct {
+	ID         string `json:"id"`
+	Stream     string `json:"stream"`
+	Output     string `json:"output"`
+	Offset     int64  `json:"offset"`
+	NextOffset int64  `json:"next_offset"`
+	Size       int64  `json:"size"`
+}
+
+// CommandOutput is a tool that reads the full output of a command whose output was truncated.
+type CommandOutput struct{}
+
+// Definition returns the mcp.Tool definition.
+func (c *CommandOutput) Definition() mcp.Tool {
+	return mcp.NewTool("command_output",
+		mcp.WithDescription("Reads the full output of a command from perform_command whose output was truncated, by its output_id. "+
+			"Pass the returned next_offset to read the next page, the whole stream has been read once next_offset is the size. "+
+			"Stored outputs are removed once they are older than the output retention of the server (a day by default)."),
+		mcp.WithString("output_id",
+			mcp.Required(),
+			mcp.Description("The output_id of the truncated result"),
+		),
+		mcp.WithString("stream",
+			mcp.Description("Stream of the output to read"),
+			mcp.Enum("stdout", "stderr"),
+			mcp.DefaultString("stdout"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Offset in bytes to read the output from (defaults to 0)"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of bytes to read (defaults to 64KiB)"),
+		),
+	)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *CommandOutput) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, err := request.RequireString("output_id")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		stream := request.GetString("stream", "stdout")
+		offset := request.GetInt("offset", 0)
+		if offset < 0 {
+			return mcp.NewToolResultError("offset must not be negative"), nil
+		}
+		limit := request.GetInt("limit", defaultOutputLimit)
+		if limit <= 0 {
+			limit = defaultOutputLimit
+		}
+		page, err := storageEngine.ReadOutput(id, stream, int64(offset), limit)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultStructuredOnly(commandOutputPage{
+			ID:         id,
+			Stream:     stream,
+			Output:     string(page.Data),
+			Offset:     page.Offset,
+			NextOffset: page.Offset + int64(len(page.Data)),
+			Size:       page.Size,
+		}), nil
+	}
+}
@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&DownloadFile{})
+}
+
+// DownloadFile is a tool that downloads a file from remote machines.
+type DownloadFile struct{}
+
+// Definition returns the mcp.Tool definition.
+func (c *DownloadFile) Definition() mcp.Tool {
+	return mcp.NewTool("download_file",
+		mcp.WithDescription("Downloads a file from remote machines over SFTP, preserving its mode bits. "+
+			"The file from each host is written to <local_dir>/<name of host>/<name of file>. "+
+			"Reports the local path, the bytes written and the SHA256 checksum for each host."),
+		mcp.WithArray("name_of_hosts",
+			mcp.Required(),
+			mcp.Description("Name of the hosts"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("remote_path",
+			mcp.Required(),
+			mcp.Description("Path of the file to download from the hosts"),
+		),
+		mcp.WithString("local_dir",
+			mcp.Description("Local directory to write the downloads to (defaults to the downloads directory next to the storage file)"),
+		),
+	)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *DownloadFile) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sshNameOfHosts, err := request.RequireStringSlice("name_of_hosts")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(sshNameOfHosts) == 0 {
+			return mcp.NewToolResultError("no hosts provided"), nil
+		}
+		remotePath, err := request.RequireString("remote_path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		localDir := request.GetString("local_dir", filepath.Join(storageEngine.Dir(), "downloads"))
+
+		found, err := getHostsFromStorage(storageEngine, sshNameOfHosts)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(found) == 0 {
+			return mcp.NewToolResultError("no matching hosts found"), nil
+		}
+
+		result := performTasksOnHosts(ctx, storageEngine, manager, found, 0, func(host ssh.ClientInfo, sshClient *ssh.Client) (any, error) {
+			hostDir, err := localName(host.Name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid host directory: %w", err)
+			}
+			fileName, err := localName(path.Base(remotePath))
+			if err != nil {
+				return nil, fmt.Errorf("invalid file name: %w", err)
+			}
+			localPath := filepath.Join(localDir, hostDir, fileName)
+			transfer, err := sshClient.Download(ctx, remotePath, localPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to download file: %w", err)
+			}
+			return transfer, nil
+		})
+
+		return mcp.NewToolResultStructuredOnly(result), nil
+	}
+}
+
+// localName returns the name as a single local path element, separators are replaced so a name
+// is used as one directory or file. Names like . and .. are rejected as they would not stay inside
+// the directory they are joined to.
+func localName(name string) (string, error) {
+	name = strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(name)
+	if name == "." || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("%q cannot be used as a local name", name)
+	}
+	return name, nil
+}
@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+	"github.com/blakerouse/sshai/utils"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&EditRemoteFile{})
+}
+
+// EditRemoteFile is a tool that edits a file on remote machines.
+type EditRemoteFile struct{}
+
+// Definition returns the mcp.Tool definition.
+func (c *EditRemoteFile) Definition() mcp.Tool {
+	return mcp.NewTool("edit_remote_file",
+		mcp.WithDescription("Edits a file on remote machines over SFTP, either by replacing text (search and replace) or by applying a unified diff (patch). "+
+			"The file is replaced atomically and keeps its mode bits. "+
+			"Returns a unified diff of what changed on each host."),
+		mcp.WithArray("name_of_hosts",
+			mcp.Required(),
+			mcp.Description("Name of the hosts"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("Path of the file on the hosts"),
+		),
+		mcp.WithString("search",
+			mcp.Description("The exact text to replace, it must be found in the file (and only once unless replace_all is true)"),
+		),
+		mcp.WithString("replace",
+			mcp.Description("The text to replace the search text with"),
+		),
+		mcp.WithBoolean("replace_all",
+			mcp.Description("Replace every occurrence of the search text"),
+		),
+		mcp.WithString("patch",
+			mcp.Description("Unified diff to apply to the file instead of search and replace"),
+		),
+		mcp.WithBoolean("backup",
+			mcp.Description("Copy the existing file to a timestamped .bak file next to it first"),
+		),
+	)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *EditRemoteFile) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sshNameOfHosts, err := request.RequireStringSlice("name_of_hosts")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(sshNameOfHosts) == 0 {
+			return mcp.NewToolResultError("no hosts provided"), nil
+		}
+		path, err := request.RequireString("path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		search := request.GetString("search", "")
+		replace := request.GetString("replace", "")
+		replaceAll := request.GetBool("replace_all", false)
+		patch := request.GetString("patch", "")
+		if (search == "") == (patch == "") {
+			return mcp.NewToolResultError("provide either search (with replace) or patch"), nil
+		}
+		backup := request.GetBool("backup", false)
+
+		edit := func(before string) (string, error) {
+			if patch != "" {
+				return utils.ApplyPatch(before, patch)
+			}
+			count := strings.Count(before, search)
+			switch {
+			case count == 0:
+				return "", errors.New("search text not found")
+			case count > 1 && !replaceAll:
+				return "", fmt.Errorf("search text found %d times, make it unique or set replace_all", count)
+			}
+			return strings.ReplaceAll(before, search, replace), nil
+		}
+
+		found, err := getHostsFromStorage(storageEngine, sshNameOfHosts)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(found) == 0 {
+			return mcp.NewToolResultError("no matching hosts found"), nil
+		}
+
+		result := performTasksOnHosts(ctx, storageEngine, manager, found, 0, func(_ ssh.ClientInfo, sshClient *ssh.Client) (any, error) {
+			before, err := readWholeFile(ctx, sshClient, path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read file: %w", err)
+			}
+			after, err := edit(before)
+			if err != nil {
+				return nil, fmt.Errorf("failed to edit %s: %w", path, err)
+			}
+			if after == before {
+				// nothing to write
+				return newFileChange(&ssh.WriteResult{Path: path, Bytes: int64(len(after))}, before, after), nil
+			}
+			written, err := sshClient.WriteFile(ctx, path, []byte(after), backup)
+			if err != nil {
+				return nil, fmt.Errorf("failed to write file: %w", err)
+			}
+			return newFileChange(written, before, after), nil
+		})
+
+		return mcp.NewToolResultStructuredOnly(result), nil
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/openai/openai-go/v2"
 
+	"github.com/blakerouse/sshai/ssh"
 	"github.com/blakerouse/sshai/storage"
 )
 
@@ -17,27 +19,58 @@ func init() {
 	Registry.Register(&GetHosts{})
 }
 
+// hostEntry is a host in the list of hosts.
+type hostEntry struct {
+	ssh.ClientInfo
+
+	CertExpiresAt *time.Time `json:"cert_expires_at,omitempty"`
+	CertExpired   bool       `json:"cert_expired,omitempty"`
+	CertError     string     `json:"cert_error,omitempty"`
+}
+
 // GetHosts is a tool that retrieves the list of hosts from the SSH configuration.
 type GetHosts struct{}
 
 // Definition returns the mcp.Tool definition.
 func (c *GetHosts) Definition() mcp.Tool {
 	return mcp.NewTool("get_hosts",
-		mcp.WithDescription("Retrieves the list of hosts from the SSH configuration."),
+		mcp.WithDescription("Retrieves the list of hosts from the SSH configuration. "+
+			"Hosts show the key exchange, host key, cipher and MAC negotiated on the last connection to them."),
 	)
 }
 
 // Handle is the function that is called when the tool is invoked.
-func (c *GetHosts) Handler(storageEngine *storage.Engine, aiClient openai.Client) server.ToolHandlerFunc {
+func (c *GetHosts) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		hosts, err := storageEngine.List()
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Errorf("failed to list hosts: %w", err).Error()), nil
 		}
+		entries := make([]hostEntry, 0, len(hosts))
 		list := make([]string, 0, len(hosts))
 		for _, host := range hosts {
-			list = append(list, host.Name)
+			entry := hostEntry{
+				ClientInfo: host.Redacted(),
+			}
+			name := host.Name
+
+			// show when the certificate expires so it's known when the host will start failing authentication
+			expiresAt, ok, err := host.CertificateExpiry()
+			if err != nil {
+				entry.CertError = err.Error()
+			} else if ok {
+				entry.CertExpiresAt = &expiresAt
+				entry.CertExpired = time.Now().After(expiresAt)
+				if entry.CertExpired {
+					name = fmt.Sprintf("%s (certificate expired %s)", name, expiresAt.Format(time.RFC3339))
+				} else {
+					name = fmt.Sprintf("%s (certificate expires %s)", name, expiresAt.Format(time.RFC3339))
+				}
+			}
+
+			entries = append(entries, entry)
+			list = append(list, name)
 		}
-		return mcp.NewToolResultStructured(hosts, strings.Join(list, ", ")), nil
+		return mcp.NewToolResultStructured(entries, strings.Join(list, ", ")), nil
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/openai/openai-go/v2"
 
+	"github.com/blakerouse/sshai/ssh"
 	"github.com/blakerouse/sshai/storage"
 )
 
@@ -21,7 +22,7 @@ type GetOSInfo struct{}
 // Definition returns the mcp.Tool definition.
 func (c *GetOSInfo) Definition() mcp.Tool {
 	return mcp.NewTool("get_os_info",
-		mcp.WithDescription("Retrieves the operating system information."),
+		mcp.WithDescription("Retrieves the operating system information, including the shell that runs the commands on each host (sh, cmd or powershell)."),
 		mcp.WithArray("name_of_hosts",
 			mcp.Required(),
 			mcp.Description("Name of the hosts"),
@@ -31,7 +32,7 @@ func (c *GetOSInfo) Definition() mcp.Tool {
 }
 
 // Handle is the function that is called when the tool is invoked.
-func (c *GetOSInfo) Handler(storageEngine *storage.Engine, aiClient openai.Client) server.ToolHandlerFunc {
+func (c *GetOSInfo) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		sshNameOfHosts, err := request.RequireStringSlice("name_of_hosts")
 		if err != nil {
@@ -49,6 +50,10 @@ func (c *GetOSInfo) Handler(storageEngine *storage.Engine, aiClient openai.Clien
 			return mcp.NewToolResultError("no matching hosts found"), nil
 		}
 
-		return mcp.NewToolResultStructuredOnly(found), nil
+		redacted := make([]ssh.ClientInfo, 0, len(found))
+		for _, host := range found {
+			redacted = append(redacted, host.Redacted())
+		}
+		return mcp.NewToolResultStructuredOnly(redacted), nil
 	}
 }
@@ -1,12 +1,18 @@
 package tools
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/blakerouse/sshai/ssh"
 	"github.com/blakerouse/sshai/storage"
+	"github.com/blakerouse/sshai/utils"
 )
 
 // getHostsFromStorage takes a list of names and finds the hosts for those names
@@ -30,12 +36,23 @@ func getHostsFromStorage(storageEngine *storage.Engine, names []string) ([]ssh.C
 // taskResult is a single result on that host
 type taskResult struct {
 	Host   string `json:"host"`
-	Result string `json:"result"`
-	Err    error  `json:"error"`
+	Result any    `json:"result,omitempty"`
+	Err    string `json:"error,omitempty"`
+}
+
+// newTaskResult creates the result of the task on the host.
+func newTaskResult(host string, result any, err error) taskResult {
+	r := taskResult{Host: host, Result: result}
+	if err != nil {
+		r.Err = err.Error()
+	}
+	return r
 }
 
 // performTasksOnHosts performs the task on all hosts in parallel
-func performTasksOnHosts(hosts []ssh.ClientInfo, task func(host ssh.ClientInfo, sshClient *ssh.Client) (string, error)) map[string]taskResult {
+//
+// A connectTimeout other than zero limits the connection to each host, together with the connect timeout of the host.
+func performTasksOnHosts(ctx context.Context, storageEngine *storage.Engine, manager *ssh.Manager, hosts []ssh.ClientInfo, connectTimeout time.Duration, task func(host ssh.ClientInfo, sshClient *ssh.Client) (any, error)) map[string]taskResult {
 	var wg sync.WaitGroup
 	wg.Add(len(hosts))
 
@@ -45,19 +62,24 @@ func performTasksOnHosts(hosts []ssh.ClientInfo, task func(host ssh.ClientInfo,
 	for _, host := range hosts {
 		go func(host ssh.ClientInfo) {
 			defer wg.Done()
-			sshClient := ssh.NewClient(&host)
-			err := sshClient.Connect()
+			connectCtx := ctx
+			if connectTimeout > 0 {
+				var cancel context.CancelFunc
+				connectCtx, cancel = context.WithTimeout(ctx, connectTimeout)
+				defer cancel()
+			}
+			sshClient, release, err := getClient(connectCtx, storageEngine, manager, host)
 			if err != nil {
 				resultsMx.Lock()
-				results[host.Name] = taskResult{Host: host.Name, Err: err}
+				results[host.Name] = newTaskResult(host.Name, nil, err)
 				resultsMx.Unlock()
 				return
 			}
-			defer sshClient.Close()
+			defer release()
 
 			result, err := task(host, sshClient)
 			resultsMx.Lock()
-			results[host.Name] = taskResult{Host: host.Name, Result: result, Err: err}
+			results[host.Name] = newTaskResult(host.Name, result, err)
 			resultsMx.Unlock()
 		}(host)
 	}
@@ -65,3 +87,206 @@ func performTasksOnHosts(hosts []ssh.ClientInfo, task func(host ssh.ClientInfo,
 
 	return results
 }
+
+// clientOptions returns the options for the clients created during the tool call.
+func clientOptions(ctx context.Context, storageEngine *storage.Engine, trustOnFirstUse bool) []ssh.ClientOption {
+	return []ssh.ClientOption{
+		ssh.WithKnownHosts(storageEngine.KnownHosts(), trustOnFirstUse),
+		ssh.WithKeyboardInteractive(elicitChallenge(ctx)),
+	}
+}
+
+// getClient returns the connected client for the host from the connection manager.
+//
+// The returned release function must be called once the client is no longer used.
+func getClient(ctx context.Context, storageEngine *storage.Engine, manager *ssh.Manager, host ssh.ClientInfo) (*ssh.Client, func(), error) {
+	chain, err := resolveJumpHosts(storageEngine, host)
+	if err != nil {
+		return nil, nil, err
+	}
+	sshClient, release, err := manager.Get(ctx, host, chain, clientOptions(ctx, storageEngine, false)...)
+	if err != nil {
+		return nil, nil, withHostKeyHint(err)
+	}
+	// the algorithms are only saved when they changed, so a reused connection does not write the storage
+	err = storageEngine.SetAlgorithms(host.Name, sshClient.Algorithms())
+	if err != nil {
+		release()
+		return nil, nil, fmt.Errorf("failed to save negotiated algorithms: %w", err)
+	}
+	return sshClient, release, nil
+}
+
+// newClient creates a client for the host that is not kept open by the connection manager.
+//
+// The connections to the jump hosts of the host are still from the connection manager, so the
+// returned release function must be called once the client is closed.
+func newClient(ctx context.Context, storageEngine *storage.Engine, manager *ssh.Manager, host *ssh.ClientInfo, trustOnFirstUse bool) (*ssh.Client, func(), error) {
+	opts := append(manager.ClientOptions(), clientOptions(ctx, storageEngine, trustOnFirstUse)...)
+	chain, err := resolveJumpHosts(storageEngine, *host)
+	if err != nil {
+		return nil, nil, err
+	}
+	release := func() {}
+	if len(chain) > 0 {
+		last := chain[len(chain)-1]
+		jump, releaseJump, err := manager.Get(ctx, last, chain[:len(chain)-1], opts...)
+		if err != nil {
+			return nil, nil, withHostKeyHint(fmt.Errorf("failed to connect to jump host %s: %w", last.Name, err))
+		}
+		opts = append(opts, ssh.WithJump(jump))
+		release = releaseJump
+	}
+	return ssh.NewClient(host, opts...), release, nil
+}
+
+// resolveJumpHosts returns the chain of jump hosts to connect through to reach the host.
+//
+// A jump host is either the name of a stored host (which can have jump hosts of its own) or an
+// SSH connection string. A connection string without a password uses the same keys as the host and
+// a connection string uses the same proxy as the host.
+func resolveJumpHosts(storageEngine *storage.Engine, host ssh.ClientInfo) ([]ssh.ClientInfo, error) {
+	return resolveJumpChain(storageEngine, host, map[string]bool{host.Name: true})
+}
+
+func resolveJumpChain(storageEngine *storage.Engine, host ssh.ClientInfo, visited map[string]bool) ([]ssh.ClientInfo, error) {
+	var chain []ssh.ClientInfo
+	for _, jump := range host.Jump {
+		if strings.HasPrefix(jump, "ssh://") {
+			info, err := ssh.NewClientInfo("", jump)
+			if err != nil {
+				return nil, fmt.Errorf("invalid jump host for %s: %w", host.Name, err)
+			}
+			if info.Pass == "" {
+				info.KeyPath = host.KeyPath
+				info.KeyPassphrase = host.KeyPassphrase
+				info.UseAgent = host.UseAgent
+			}
+			info.Proxy = host.Proxy
+			chain = append(chain, *info)
+			continue
+		}
+		if visited[jump] {
+			return nil, fmt.Errorf("jump host %s for %s creates a loop", jump, host.Name)
+		}
+		stored, ok := storageEngine.Get(jump)
+		if !ok {
+			return nil, fmt.Errorf("jump host %s for %s not found", jump, host.Name)
+		}
+		visited[jump] = true
+		storedChain, err := resolveJumpChain(storageEngine, stored, visited)
+		delete(visited, jump)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, storedChain...)
+		chain = append(chain, stored)
+	}
+	return chain, nil
+}
+
+// withHostKeyHint adds a hint on how to resolve host key verification errors.
+func withHostKeyHint(err error) error {
+	var unknownErr *ssh.UnknownHostKeyError
+	var mismatchErr *ssh.HostKeyMismatchError
+	switch {
+	case errors.As(err, &mismatchErr):
+		return fmt.Errorf("%w (if the host was legitimately rebuilt use accept_host_key to trust the new key)", err)
+	case errors.As(err, &unknownErr):
+		return fmt.Errorf("%w (use accept_host_key to trust the key)", err)
+	}
+	return err
+}
+
+// execOutput runs the command on the host and returns its output, failing when the command does not exit successfully.
+func execOutput(ctx context.Context, sshClient *ssh.Client, cmd string) (string, error) {
+	result, err := sshClient.Exec(ctx, cmd)
+	if err != nil {
+		return "", err
+	}
+	err = result.Err()
+	if err != nil {
+		return "", err
+	}
+	return result.Stdout, nil
+}
+
+// maxFileSize is the largest remote file that is read, written or edited as a whole by the file tools.
+const maxFileSize = 1 << 20
+
+// readWholeFile reads the whole remote file for editing, a missing file returns an error that matches fs.ErrNotExist.
+func readWholeFile(ctx context.Context, sshClient *ssh.Client, path string) (string, error) {
+	content, err := sshClient.ReadFile(ctx, path, 0, maxFileSize)
+	if err != nil {
+		return "", err
+	}
+	if content.Truncated {
+		return "", fmt.Errorf("%s is %d bytes, larger than the %d bytes that can be edited", path, content.Size, maxFileSize)
+	}
+	return string(content.Data), nil
+}
+
+// fileChange is the result of changing a remote file.
+type fileChange struct {
+	*ssh.WriteResult
+	Diff string `json:"diff"`
+}
+
+// newFileChange creates the result of changing the remote file from the content before and after the change.
+func newFileChange(result *ssh.WriteResult, before string, after string) fileChange {
+	change := fileChange{WriteResult: result}
+	if !utf8.ValidString(before) || !utf8.ValidString(after) {
+		change.Diff = fmt.Sprintf("Binary file %s changed", result.Path)
+	} else {
+		change.Diff = utils.UnifiedDiff(result.Path, before, after)
+	}
+	return change
+}
+
+// getStringMap returns the object argument with string values, nil when it is not provided.
+func getStringMap(args map[string]any, key string) (map[string]string, error) {
+	value, ok := args[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	object, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object", key)
+	}
+	result := make(map[string]string, len(object))
+	for name, v := range object {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s must be a string", key, name)
+		}
+		result[name] = str
+	}
+	return result, nil
+}
+
+// splitList returns the items of the comma separated list, nil when it is empty.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// decodeContent decodes the content from the encoding (text or base64).
+func decodeContent(content string, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", "text":
+		return []byte(content), nil
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 content: %w", err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unknown encoding %q", encoding)
+}
@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&ImportSSHConfig{})
+}
+
+// DefaultSSHConfigPath is the default path of the OpenSSH client configuration.
+const DefaultSSHConfigPath = "~/.ssh/config"
+
+// ImportedHost is a host imported from the OpenSSH client configuration.
+type ImportedHost struct {
+	Name     string   `json:"name"`
+	Unmapped []string `json:"unmapped,omitempty"`
+}
+
+// ImportResult is the result of importing hosts from the OpenSSH client configuration.
+type ImportResult struct {
+	Hosts    []ImportedHost `json:"hosts"`
+	Warnings []string       `json:"warnings,omitempty"`
+}
+
+// ImportHostsFromSSHConfig imports the hosts matching the patterns (all hosts when empty) from the OpenSSH
+// client configuration at path into the storage.
+//
+// Hosts that are already stored are updated but keep what the configuration cannot express (see keepStoredHost).
+func ImportHostsFromSSHConfig(storageEngine *storage.Engine, path string, patterns []string) (*ImportResult, error) {
+	cfg, err := ssh.ParseOpenSSHConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := cfg.Select(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{
+		Warnings: cfg.Warnings,
+	}
+	for _, alias := range aliases {
+		info, unmapped, err := cfg.ClientInfo(alias)
+		if err != nil {
+			return nil, err
+		}
+		if existing, ok := storageEngine.Get(alias); ok {
+			keepStoredHost(info, existing)
+		}
+		err = storageEngine.Set(*info)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add host %s to storage: %w", alias, err)
+		}
+		result.Hosts = append(result.Hosts, ImportedHost{Name: alias, Unmapped: unmapped})
+	}
+	return result, nil
+}
+
+// keepStoredHost keeps the information of the stored host that the OpenSSH client configuration cannot
+// express (the secrets, how to become another user, the proxy and the command timeout) and the cached
+// OS information. The environment and connect timeout are kept when the configuration does not set them.
+func keepStoredHost(info *ssh.ClientInfo, existing ssh.ClientInfo) {
+	info.Pass = existing.Pass
+	info.KeyPassphrase = existing.KeyPassphrase
+	info.KeyboardInteractive = existing.KeyboardInteractive
+	info.BecomeMethod = existing.BecomeMethod
+	info.BecomePass = existing.BecomePass
+	info.Proxy = existing.Proxy
+	info.CommandTimeout = existing.CommandTimeout
+	if info.ConnectTimeout == "" {
+		info.ConnectTimeout = existing.ConnectTimeout
+	}
+	if len(info.Env) == 0 {
+		info.Env = existing.Env
+	}
+	info.OS = existing.OS
+	info.Algorithms = existing.Algorithms
+}
+
+// ImportSSHConfig is a tool that imports hosts from the OpenSSH client configuration.
+type ImportSSHConfig struct{}
+
+// Definition returns the mcp.Tool definition.
+func (c *ImportSSHConfig) Definition() mcp.Tool {
+	return mcp.NewTool("import_ssh_config",
+		mcp.WithDescription("Imports hosts from an OpenSSH client configuration file (~/.ssh/config). "+
+			"Reports the options of each host that could not be imported. "+
+			"The host keys of imported hosts must be accepted with accept_host_key unless they are in the known_hosts file."),
+		mcp.WithString("path",
+			mcp.Description("Path of the OpenSSH client configuration file (defaults to ~/.ssh/config)"),
+		),
+		mcp.WithArray("hosts",
+			mcp.Description("Host patterns (wildcards supported) to import, all hosts are imported when not provided"),
+			mcp.WithStringItems(),
+		),
+	)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *ImportSSHConfig) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path := request.GetString("path", DefaultSSHConfigPath)
+		patterns := request.GetStringSlice("hosts", nil)
+
+		result, err := ImportHostsFromSSHConfig(storageEngine, path, patterns)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		names := make([]string, 0, len(result.Hosts))
+		for _, host := range result.Hosts {
+			names = append(names, host.Name)
+		}
+		return mcp.NewToolResultStructured(result, fmt.Sprintf("imported %s", strings.Join(names, ", "))), nil
+	}
+}
@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func TestImportHostsFromSSHConfig_KeepsStoredHost(t *testing.T) {
+	dir := t.TempDir()
+	storageEngine, err := storage.NewEngine(filepath.Join(dir, "hosts.yaml"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	err = storageEngine.Set(ssh.ClientInfo{
+		Name:           "web1",
+		Host:           "10.0.0.1",
+		Port:           "22",
+		User:           "deploy",
+		Pass:           "password",
+		BecomeMethod:   ssh.BecomeSu,
+		BecomePass:     "root password",
+		Proxy:          "socks5://proxy:1080",
+		CommandTimeout: "5m",
+		Env:            map[string]string{"LANG": "C"},
+		OS:             ssh.OSInfo{Name: "Ubuntu", Platform: "ubuntu", Version: "24.04", Arch: "x86_64"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	configPath := filepath.Join(dir, "config")
+	err = os.WriteFile(configPath, []byte("Host web1\n  HostName 10.0.0.2\n  User deploy\n  Port 2222\n"), 0600)
+	if err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	_, err = ImportHostsFromSSHConfig(storageEngine, configPath, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	host, ok := storageEngine.Get("web1")
+	if !ok {
+		t.Fatal("expected web1 to be stored")
+	}
+	// the configuration updates the host, what it cannot express is kept
+	if host.Host != "10.0.0.2" || host.Port != "2222" {
+		t.Errorf("expected the host to be updated, got %s:%s", host.Host, host.Port)
+	}
+	if host.Pass != "password" || host.BecomeMethod != ssh.BecomeSu || host.BecomePass != "root password" {
+		t.Errorf("expected the stored secrets to be kept, got %+v", host)
+	}
+	if host.Proxy != "socks5://proxy:1080" || host.CommandTimeout != "5m" || host.Env["LANG"] != "C" {
+		t.Errorf("expected the stored settings to be kept, got %+v", host)
+	}
+	if host.OS.Name != "Ubuntu" {
+		t.Errorf("expected the OS information to be kept, got %+v", host.OS)
+	}
+}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"github.com/blakerouse/sshai/ssh"
 	"github.com/blakerouse/sshai/storage"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -10,5 +11,5 @@ import (
 // Tool defines the interface that provides both the definition and the handler for a tool.
 type Tool interface {
 	Definition() mcp.Tool
-	Handler(*storage.Engine, openai.Client) server.ToolHandlerFunc
+	Handler(*storage.Engine, *ssh.Manager, openai.Client) server.ToolHandlerFunc
 }
@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&JobOutput{})
+}
+
+// defaultOutputLimit is the number of bytes of output returned when no limit is provided.
+const defaultOutputLimit = 64 * 1024
+
+// JobOutput is a tool that reads the output of a background job.
+type JobOutput struct{}
+
+// Definition returns the mcp.Tool definition.
+func (c *JobOutput) Definition() mcp.Tool {
+	return mcp.NewTool("job_output",
+		mcp.WithDescription("Reads the combined output of a background job from an offset. "+
+			"Pass the returned next_offset to read only the new output on the next call, "+
+			"dropped reports output that was too old to be kept."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("ID of the job"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Offset in bytes to read the output from (defaults to 0)"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of bytes to read (defaults to 64KiB)"),
+		),
+	)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *JobOutput) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, err := request.RequireString("id")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		offset := request.GetInt("offset", 0)
+		if offset < 0 {
+			return mcp.NewToolResultError("offset must not be negative"), nil
+		}
+		limit := request.GetInt("limit", defaultOutputLimit)
+		if limit <= 0 {
+			limit = defaultOutputLimit
+		}
+		output, err := manager.JobOutput(id, int64(offset), limit)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultStructuredOnly(output), nil
+	}
+}
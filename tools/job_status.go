@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&JobStatus{})
+}
+
+// JobStatus is a tool that shows the state of background jobs.
+type JobStatus struct{}
+
+// Definition returns the mcp.Tool definition.
+func (c *JobStatus) Definition() mcp.Tool {
+	return mcp.NewTool("job_status",
+		mcp.WithDescription("Shows if a background job is still running, or how it finished (exit code, signal or error), and how much output it has. "+
+			"Lists all the jobs when no ID is provided."),
+		mcp.WithString("id",
+			mcp.Description("ID of the job"),
+		),
+	)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *JobStatus) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id := request.GetString("id", "")
+		if id == "" {
+			return mcp.NewToolResultStructuredOnly(map[string]any{
+				"jobs": manager.Jobs(),
+			}), nil
+		}
+		job, err := manager.Job(id)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultStructuredOnly(job), nil
+	}
+}
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/blakerouse/sshai/ssh"
+)
+
+// elicitMx ensures only one keyboard-interactive challenge is asked of the user at a time.
+var elicitMx sync.Mutex
+
+// elicitChallenge returns the keyboard-interactive challenge that asks the user to answer the questions
+// through MCP elicitation.
+//
+// The answers are only passed to the server and never stored.
+func elicitChallenge(ctx context.Context) ssh.KeyboardInteractiveChallenge {
+	return func(host, name, instruction string, questions []string, echos []bool) ([]string, error) {
+		mcpServer := server.ServerFromContext(ctx)
+		if mcpServer == nil {
+			return nil, ssh.ErrNoKeyboardInteractive
+		}
+
+		properties := make(map[string]any, len(questions))
+		required := make([]string, 0, len(questions))
+		for i, question := range questions {
+			key := fmt.Sprintf("answer_%d", i)
+			properties[key] = map[string]any{
+				"type":        "string",
+				"title":       strings.TrimSpace(question),
+				"description": fmt.Sprintf("Answer to %q asked by %s", strings.TrimSpace(question), host),
+			}
+			required = append(required, key)
+		}
+		message := fmt.Sprintf("%s requires keyboard-interactive authentication", host)
+		for _, part := range []string{name, instruction} {
+			part = strings.TrimSpace(part)
+			if part != "" {
+				message = fmt.Sprintf("%s: %s", message, part)
+			}
+		}
+
+		elicitMx.Lock()
+		defer elicitMx.Unlock()
+		result, err := mcpServer.RequestElicitation(ctx, mcp.ElicitationRequest{
+			Params: mcp.ElicitationParams{
+				Message: message,
+				RequestedSchema: map[string]any{
+					"type":       "object",
+					"properties": properties,
+					"required":   required,
+				},
+			},
+		})
+		if err != nil {
+			if errors.Is(err, server.ErrElicitationNotSupported) || errors.Is(err, server.ErrNoActiveSession) {
+				return nil, fmt.Errorf("%w: %w", ssh.ErrNoKeyboardInteractive, err)
+			}
+			return nil, fmt.Errorf("failed to ask for keyboard-interactive answers: %w", err)
+		}
+		if result.Action != mcp.ElicitationResponseActionAccept {
+			return nil, fmt.Errorf("keyboard-interactive authentication for %s was not answered by the user (%s)", host, result.Action)
+		}
+		content, ok := result.Content.(map[string]any)
+		if !ok {
+			return nil, errors.New("invalid keyboard-interactive answers")
+		}
+		answers := make([]string, len(questions))
+		for i := range questions {
+			answer, ok := content[fmt.Sprintf("answer_%d", i)].(string)
+			if !ok {
+				return nil, fmt.Errorf("missing keyboard-interactive answer for %q", questions[i])
+			}
+			answers[i] = answer
+		}
+		return answers, nil
+	}
+}
@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&KillJob{})
+}
+
+// KillJob is a tool that sends a signal to a background job.
+type KillJob struct{}
+
+// Definition returns the mcp.Tool definition.
+func (c *KillJob) Definition() mcp.Tool {
+	return mcp.NewTool("kill_job",
+		mcp.WithDescription("Sends a signal to the command of a background job. "+
+			"KILL also closes the session, which ends the command on servers that ignore signals."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("ID of the job"),
+		),
+		mcp.WithString("signal",
+			mcp.Description("Signal to send"),
+			mcp.Enum("TERM", "INT", "HUP", "QUIT", "KILL", "USR1", "USR2"),
+			mcp.DefaultString("TERM"),
+		),
+	)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *KillJob) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, err := request.RequireString("id")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		job, err := manager.SignalJob(id, request.GetString("signal", "TERM"))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultStructuredOnly(job), nil
+	}
+}
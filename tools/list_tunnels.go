@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&ListTunnels{})
+}
+
+// ListTunnels is a tool that lists the open port forwards.
+type ListTunnels struct{}
+
+// Definition returns the mcp.Tool definition.
+func (c *ListTunnels) Definition() mcp.Tool {
+	return mcp.NewTool("list_tunnels",
+		mcp.WithDescription("Lists the port forwards with their connection and byte counters. "+
+			"Port forwards whose connection to the host was lost are listed as failed until they are closed with close_tunnel."),
+	)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *ListTunnels) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"tunnels": manager.Tunnels(),
+		}), nil
+	}
+}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// outputNotifier sends the output of commands to the MCP client while they run.
+//
+// Each line is sent as a log message with the host as the logger, and also as a progress notification
+// when the client asked for progress on the tool call.
+type outputNotifier struct {
+	ctx       context.Context
+	mcpServer *server.MCPServer
+	token     mcp.ProgressToken
+
+	mx       sync.Mutex
+	progress float64
+}
+
+// newOutputNotifier creates the output notifier for the tool call.
+func newOutputNotifier(ctx context.Context, request mcp.CallToolRequest) *outputNotifier {
+	n := &outputNotifier{
+		ctx:       ctx,
+		mcpServer: server.ServerFromContext(ctx),
+	}
+	if request.Params.Meta != nil {
+		n.token = request.Params.Meta.ProgressToken
+	}
+	return n
+}
+
+// Host returns the function that sends the lines of output from the host.
+func (n *outputNotifier) Host(host string) func(stream string, line string) {
+	return func(stream string, line string) {
+		if n.mcpServer == nil {
+			return
+		}
+		// notifications are best effort, the full output is still in the result
+		_ = n.mcpServer.SendLogMessageToClient(n.ctx, mcp.NewLoggingMessageNotification(mcp.LoggingLevelInfo, host, map[string]any{
+			"host":   host,
+			"stream": stream,
+			"line":   line,
+		}))
+		if n.token == nil {
+			return
+		}
+		// progress must increase with every notification for the token
+		n.mx.Lock()
+		defer n.mx.Unlock()
+		n.progress++
+		_ = n.mcpServer.SendNotificationToClient(n.ctx, "notifications/progress", map[string]any{
+			"progressToken": n.token,
+			"progress":      n.progress,
+			"message":       fmt.Sprintf("[%s] %s", host, line),
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&OpenTunnel{})
+}
+
+// OpenTunnel is a tool that opens a port forward through a remote machine.
+type OpenTunnel struct{}
+
+// Definition returns the mcp.Tool definition.
+func (c *OpenTunnel) Definition() mcp.Tool {
+	return mcp.NewTool("open_tunnel",
+		mcp.WithDescription("Opens a port forward through a remote machine that stays open until it is closed with close_tunnel. "+
+			"A local forward (like ssh -L) listens locally and connects to the target from the host, "+
+			"a remote forward (like ssh -R) listens on the host and connects to the target from this machine."),
+		mcp.WithString("name_of_host",
+			mcp.Required(),
+			mcp.Description("Name of the host"),
+		),
+		mcp.WithString("direction",
+			mcp.Required(),
+			mcp.Description("Direction of the port forward"),
+			mcp.Enum(ssh.ForwardLocal, ssh.ForwardRemote),
+		),
+		mcp.WithString("listen_address",
+			mcp.Required(),
+			mcp.Description("Address (host:port) or port to listen on, a port only listens on the loopback interface and port 0 picks a free port"),
+		),
+		mcp.WithString("target_address",
+			mcp.Required(),
+			mcp.Description("Address (host:port) to forward the connections to"),
+		),
+	)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *OpenTunnel) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sshNameOfHost, err := request.RequireString("name_of_host")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		direction, err := request.RequireString("direction")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		listenAddress, err := request.RequireString("listen_address")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		targetAddress, err := request.RequireString("target_address")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		host, ok := storageEngine.Get(sshNameOfHost)
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("host %s not found", sshNameOfHost)), nil
+		}
+		sshClient, release, err := getClient(ctx, storageEngine, manager, host)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		// the tunnel keeps the connection until it is closed
+		tunnel, err := manager.Forward(sshClient, release, ssh.ForwardSpec{
+			Direction:     direction,
+			ListenAddress: listenAddress,
+			TargetAddress: targetAddress,
+		})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultStructured(tunnel, fmt.Sprintf("opened %s listening on %s forwarding to %s",
+			tunnel.ID, tunnel.ListenAddress, tunnel.TargetAddress)), nil
+	}
+}
@@ -0,0 +1,220 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/utils"
+)
+
+// osCommandOutput is the output of a command that identifies the operating system.
+type osCommandOutput struct {
+	command string
+	output  string
+}
+
+// windowsOSCommand describes the Windows installation, it works from both cmd.exe and PowerShell.
+const windowsOSCommand = `powershell -NoProfile -NonInteractive -Command "Get-CimInstance Win32_OperatingSystem | Select-Object Caption,Version,BuildNumber,OSArchitecture | ConvertTo-Json"`
+
+// osDetector identifies a family of operating systems with a command that only succeeds on that family.
+type osDetector struct {
+	// detect is the command that must succeed
+	detect string
+	// commands are run after detecting for more details, their failures are ignored
+	commands []string
+	// parse returns the OS information from the outputs of detect and commands (empty when they failed)
+	parse func(outputs []string) (*ssh.OSInfo, error)
+	// shell returns the shell that runs the commands, ShellPOSIX when nil
+	shell func(ctx context.Context, sshClient *ssh.Client) string
+}
+
+// osDetectors are tried in order until one detects the operating system.
+var osDetectors = []osDetector{
+	// Linux
+	{
+		detect:   "cat /etc/os-release",
+		commands: []string{"uname -srm"},
+		parse: func(outputs []string) (*ssh.OSInfo, error) {
+			return ssh.ParseOSRelease(outputs[0], outputs[1])
+		},
+	},
+	// macOS
+	{
+		detect:   "sw_vers",
+		commands: []string{"uname -srm"},
+		parse: func(outputs []string) (*ssh.OSInfo, error) {
+			return ssh.ParseSwVers(outputs[0], outputs[1])
+		},
+	},
+	// FreeBSD
+	{
+		detect:   "freebsd-version",
+		commands: []string{"uname -srm"},
+		parse: func(outputs []string) (*ssh.OSInfo, error) {
+			return ssh.ParseFreeBSDVersion(outputs[0], outputs[1])
+		},
+	},
+	// Windows running OpenSSH, before uname which also succeeds when Git or MSYS is on the PATH
+	{
+		detect:   "cmd /c ver",
+		commands: []string{windowsOSCommand},
+		parse: func(outputs []string) (*ssh.OSInfo, error) {
+			return ssh.ParseWindowsOS(outputs[1])
+		},
+		shell: windowsShell,
+	},
+	// the other BSDs and Unix-like systems
+	{
+		detect: "uname -srm",
+		parse: func(outputs []string) (*ssh.OSInfo, error) {
+			return ssh.ParseUname(outputs[0])
+		},
+	},
+}
+
+// detectOSInfo identifies the operating system of the host and the shell that runs its commands.
+//
+// The output of the commands is parsed locally, OpenAI only summarizes the output that is not recognized.
+func detectOSInfo(ctx context.Context, aiClient openai.Client, sshClient *ssh.Client) (*ssh.OSInfo, error) {
+	detector, outputs, err := detectOS(ctx, sshClient)
+	if err != nil {
+		return nil, err
+	}
+
+	osInfo, err := detector.parse(outputs)
+	if err != nil && !aiConfigured(aiClient) {
+		return nil, fmt.Errorf("failed to parse OS information (provide an OpenAI API key to summarize it instead): %w", err)
+	}
+	if err != nil {
+		// send the output to OpenAI to get a summary of what needs to be updated
+		var aiErr error
+		osInfo, aiErr = getOSInfo(ctx, aiClient, detector.commandOutputs(outputs))
+		if aiErr != nil {
+			return nil, fmt.Errorf("failed to parse OS information: %w, and failed to summarize it: %w", err, aiErr)
+		}
+	}
+	osInfo.Shell = ssh.ShellPOSIX
+	if detector.shell != nil {
+		osInfo.Shell = detector.shell(ctx, sshClient)
+	}
+	return osInfo, nil
+}
+
+// aiConfigured returns true when an OpenAI API key was provided, the client is the zero value otherwise.
+func aiConfigured(aiClient openai.Client) bool {
+	return aiClient.Options != nil
+}
+
+// detectOS runs the commands that identify the operating system with the first detector that succeeds.
+func detectOS(ctx context.Context, sshClient *ssh.Client) (*osDetector, []string, error) {
+	var errs []error
+	for i, detector := range osDetectors {
+		output, err := execOutput(ctx, sshClient, detector.detect)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", detector.detect, err))
+			continue
+		}
+		outputs := []string{output}
+		for _, command := range detector.commands {
+			// a failed command has no output
+			output, _ := execOutput(ctx, sshClient, command)
+			outputs = append(outputs, output)
+		}
+		return &osDetectors[i], outputs, nil
+	}
+	return nil, nil, fmt.Errorf("failed to identify the operating system: %w", errors.Join(errs...))
+}
+
+// commandOutputs returns the outputs of the commands that succeeded.
+func (d *osDetector) commandOutputs(outputs []string) []osCommandOutput {
+	commands := append([]string{d.detect}, d.commands...)
+	var commandOutputs []osCommandOutput
+	for i, output := range outputs {
+		if output != "" {
+			commandOutputs = append(commandOutputs, osCommandOutput{command: commands[i], output: output})
+		}
+	}
+	return commandOutputs
+}
+
+// windowsShell returns the shell of a Windows host, which is cmd.exe unless the OpenSSH DefaultShell is PowerShell.
+func windowsShell(ctx context.Context, sshClient *ssh.Client) string {
+	// only cmd.exe expands %OS%
+	output, err := execOutput(ctx, sshClient, "echo %OS%")
+	if err == nil && strings.TrimSpace(output) == "Windows_NT" {
+		return ssh.ShellCmd
+	}
+	return ssh.ShellPowerShell
+}
+
+// summarizedOSInfo is the OS information that OpenAI summarizes, the shell is detected separately.
+//
+// A strict schema requires every property, so unlike ssh.OSInfo none of them are optional.
+type summarizedOSInfo struct {
+	Name     string `json:"name" jsonschema_description:"The name of the operating system"`
+	Platform string `json:"platform" jsonschema_description:"The platform of the operating system"`
+	Version  string `json:"version" jsonschema_description:"The version of the operating system"`
+	Arch     string `json:"arch" jsonschema_description:"The architecture of the operating system"`
+}
+
+func getOSInfo(ctx context.Context, aiClient openai.Client, outputs []osCommandOutput) (*ssh.OSInfo, error) {
+	// setup the schema to ensure the output is structured
+	schema := utils.GenerateSchema[summarizedOSInfo]()
+	schemaParam := openai.ResponseFormatJSONSchemaJSONSchemaParam{
+		Name:        "os_info",
+		Description: openai.String("OS information"),
+		Schema:      schema,
+		Strict:      openai.Bool(true),
+	}
+
+	var content strings.Builder
+	for _, output := range outputs {
+		fmt.Fprintf(&content, "--- %s ---\n%s\n", output.command, output.output)
+	}
+
+	// perform the chat to compute the output into the desired format
+	chat, err := aiClient.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(`
+			You are a helpful assistant that summarizes the output of the commands that identify
+			the operating system, like '/etc/os-release' and 'uname -srm' on Linux, 'sw_vers' on macOS,
+			'freebsd-version' on FreeBSD, 'uname -srm' on the other BSDs or 'ver' and
+			Win32_OperatingSystem on Windows. Use this information to determine the name, platform,
+			architecture, and version of the operating system.`),
+			openai.UserMessage(content.String()),
+		},
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+				JSONSchema: schemaParam,
+			},
+		},
+		// model for structured output
+		Model: openai.ChatModelGPT4o2024_08_06,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(chat.Choices) == 0 {
+		return nil, errors.New("no choices returned from OpenAI")
+	}
+
+	// extract into a well-typed struct
+	var osInfo summarizedOSInfo
+	err = json.Unmarshal([]byte(chat.Choices[0].Message.Content), &osInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal OS information: %w", err)
+	}
+
+	return &ssh.OSInfo{
+		Name:     osInfo.Name,
+		Platform: osInfo.Platform,
+		Version:  osInfo.Version,
+		Arch:     osInfo.Arch,
+	}, nil
+}
@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/blakerouse/sshai/utils"
+)
+
+func TestSummarizedOSInfo_StrictSchema(t *testing.T) {
+	data, err := json.Marshal(utils.GenerateSchema[summarizedOSInfo]())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var schema struct {
+		Properties           map[string]any `json:"properties"`
+		Required             []string       `json:"required"`
+		AdditionalProperties *bool          `json:"additionalProperties"`
+	}
+	err = json.Unmarshal(data, &schema)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// OpenAI rejects a strict schema unless every property is required
+	if len(schema.Properties) == 0 || len(schema.Required) != len(schema.Properties) {
+		t.Errorf("expected all %d properties to be required, got %v", len(schema.Properties), schema.Required)
+	}
+	for name := range schema.Properties {
+		if !slices.Contains(schema.Required, name) {
+			t.Errorf("expected %s to be required, got %v", name, schema.Required)
+		}
+	}
+	if schema.AdditionalProperties == nil || *schema.AdditionalProperties {
+		t.Errorf("expected additional properties to not be allowed")
+	}
+}
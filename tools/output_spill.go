@@ -0,0 +1,214 @@
+package tools
+
+import (
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+	"github.com/blakerouse/sshai/utils"
+)
+
+// outputSpill keeps the head and tail of each stream of the output of a command in memory, the full
+// output is streamed to a stored output once a stream is larger than the head and tail together.
+type outputSpill struct {
+	storageEngine *storage.Engine
+	head          int
+	tail          int
+
+	mx     sync.Mutex
+	output *storage.Output
+	err    error
+
+	stdout *spillStream
+	stderr *spillStream
+}
+
+// newOutputSpill creates the spill for the output of a command that keeps head and tail bytes of each stream.
+func newOutputSpill(storageEngine *storage.Engine, head int, tail int) *outputSpill {
+	s := &outputSpill{
+		storageEngine: storageEngine,
+		head:          head,
+		tail:          tail,
+	}
+	s.stdout = &spillStream{spill: s, name: "stdout"}
+	s.stderr = &spillStream{spill: s, name: "stderr"}
+	return s
+}
+
+// option returns the option that writes the output of the command to the spill.
+func (s *outputSpill) option() ssh.ExecOption {
+	return ssh.WithOutput(s.stdout, s.stderr)
+}
+
+// spilled returns true when the output was too large to keep, so the streams are truncated.
+func (s *outputSpill) spilled() bool {
+	return s.stdout.isSpilled() || s.stderr.isSpilled()
+}
+
+// close stores the streams that were small enough to keep once the other stream was spilled and closes the files.
+//
+// Returns the error when the full output could not be stored.
+func (s *outputSpill) close() error {
+	spilled := s.spilled()
+	for _, stream := range []*spillStream{s.stdout, s.stderr} {
+		stream.close(spilled)
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.err
+}
+
+// discard removes the stored output, when the command did not finish its output is not read back.
+func (s *outputSpill) discard() {
+	for _, stream := range []*spillStream{s.stdout, s.stderr} {
+		stream.close(false)
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.output != nil {
+		_ = s.output.Remove()
+	}
+}
+
+// create creates the file of the stream in the stored output, nil when storing the output failed.
+func (s *outputSpill) create(stream string) *os.File {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.err != nil {
+		return nil
+	}
+	if s.output == nil {
+		s.output, s.err = s.storageEngine.NewOutput()
+		if s.err != nil {
+			return nil
+		}
+	}
+	f, err := s.output.Create(stream)
+	if err != nil {
+		s.err = err
+		return nil
+	}
+	return f
+}
+
+// fail records the error of storing the output, only the first error is kept.
+func (s *outputSpill) fail(err error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// spillStream is a stream of the output that is kept in memory until it is too large, then it is
+// written to its file with only the head and tail kept.
+type spillStream struct {
+	spill *outputSpill
+	name  string
+
+	mx      sync.Mutex
+	head    []byte
+	tail    []byte
+	size    int64
+	spilled bool
+	closed  bool
+	file    *os.File
+}
+
+func (s *spillStream) Write(p []byte) (int, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return len(p), nil
+	}
+	s.size += int64(len(p))
+	if s.spilled {
+		s.writeFile(p)
+		s.keepTail(p)
+		return len(p), nil
+	}
+	kept := append(s.head, p...)
+	if len(kept) <= s.spill.head+s.spill.tail {
+		s.head = kept
+		return len(p), nil
+	}
+
+	// too large to keep, the whole stream is written to the file from now on
+	s.spilled = true
+	s.file = s.spill.create(s.name)
+	s.writeFile(kept)
+	s.head = slices.Clone(kept[:s.spill.head])
+	s.keepTail(kept[s.spill.head:])
+	return len(p), nil
+}
+
+// String returns the stream, truncated to its head and tail when it was spilled.
+func (s *spillStream) String() string {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if !s.spilled {
+		return string(s.head)
+	}
+	tail := s.tail[max(0, len(s.tail)-s.spill.tail):]
+	return utils.JoinTruncated(string(s.head), string(tail), s.size-int64(len(s.head)+len(tail)))
+}
+
+// Size returns the number of bytes written to the stream.
+func (s *spillStream) Size() int64 {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.size
+}
+
+func (s *spillStream) isSpilled() bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.spilled
+}
+
+// keepTail keeps the last bytes of the stream, up to twice the tail is buffered so the bytes are not
+// moved for every write.
+func (s *spillStream) keepTail(p []byte) {
+	s.tail = append(s.tail, p...)
+	if len(s.tail) > 2*s.spill.tail {
+		s.tail = append(s.tail[:0], s.tail[len(s.tail)-s.spill.tail:]...)
+	}
+}
+
+// writeFile writes to the file of the stream, the file is no longer written once writing failed.
+func (s *spillStream) writeFile(p []byte) {
+	if s.file == nil {
+		return
+	}
+	_, err := s.file.Write(p)
+	if err != nil {
+		s.spill.fail(err)
+		_ = s.file.Close()
+		s.file = nil
+	}
+}
+
+// close closes the file of the stream, the stream that was kept is stored first when store is true
+// so both streams of the output can be read back.
+func (s *spillStream) close(store bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if store && !s.spilled {
+		s.file = s.spill.create(s.name)
+		s.writeFile(s.head)
+	}
+	if s.file == nil {
+		return
+	}
+	err := s.file.Close()
+	if err != nil {
+		s.spill.fail(err)
+	}
+	s.file = nil
+}
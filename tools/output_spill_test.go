@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func TestOutputSpill(t *testing.T) {
+	storageEngine, err := storage.NewEngine(filepath.Join(t.TempDir(), "hosts.yaml"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// output that fits is kept as is and not stored
+	spill := newOutputSpill(storageEngine, 4, 4)
+	_, _ = spill.stdout.Write([]byte("short"))
+	result := newCommandResult(ssh.ClientInfo{}, &ssh.ExecResult{})
+	result.setOutput(spill)
+	if result.Truncated || result.Stdout != "short" || result.OutputID != "" {
+		t.Errorf("expected the output to be kept, got %+v", result)
+	}
+
+	// the whole output is stored while only the head and tail are kept
+	spill = newOutputSpill(storageEngine, 7, 7)
+	for _, line := range []string{"line 1\n", "line 2\n", strings.Repeat("x", 100) + "\n", "line 3\n"} {
+		_, _ = spill.stdout.Write([]byte(line))
+	}
+	_, _ = spill.stderr.Write([]byte("warning\n"))
+	result = newCommandResult(ssh.ClientInfo{}, &ssh.ExecResult{})
+	result.setOutput(spill)
+	if !result.Truncated || result.OutputID == "" || result.OutputError != "" {
+		t.Fatalf("expected the output to be stored, got %+v", result)
+	}
+	if result.Stdout != "line 1\n\n... [108 bytes truncated] ...\nline 3\n" || result.StdoutSize != 122 {
+		t.Errorf("expected the head and tail of 122 bytes, got %q of %d bytes", result.Stdout, result.StdoutSize)
+	}
+	if result.Stderr != "warning\n" {
+		t.Errorf("expected the stderr that fits to be kept, got %q", result.Stderr)
+	}
+	if len(spill.stdout.tail) > 2*7 {
+		t.Errorf("expected at most twice the tail to be buffered, got %d bytes", len(spill.stdout.tail))
+	}
+
+	// both streams are read back in full
+	page, err := storageEngine.ReadOutput(result.OutputID, "stdout", 0, 1000)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if page.Size != 122 || !strings.HasPrefix(string(page.Data), "line 1\nline 2\nxxx") {
+		t.Errorf("expected the full stdout, got %q", page.Data)
+	}
+	page, err = storageEngine.ReadOutput(result.OutputID, "stderr", 0, 1000)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if string(page.Data) != "warning\n" {
+		t.Errorf("expected the full stderr, got %q", page.Data)
+	}
+
+	// the output of a command that did not finish is removed
+	spill = newOutputSpill(storageEngine, 0, 0)
+	_, _ = spill.stdout.Write([]byte("partial"))
+	id := spill.output.ID
+	spill.discard()
+	_, err = storageEngine.ReadOutput(id, "stdout", 0, 1000)
+	if err == nil {
+		t.Errorf("expected the discarded output to be removed")
+	}
+}
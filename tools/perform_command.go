@@ -3,6 +3,8 @@ package tools
 import (
 	"context"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -17,24 +19,92 @@ func init() {
 	Registry.Register(&PerformCommand{})
 }
 
+const (
+	// defaultOutputHead is the number of bytes kept from the start of each stream when the output is truncated.
+	defaultOutputHead = 8 * 1024
+	// defaultOutputTail is the number of bytes kept from the end of each stream when the output is truncated.
+	defaultOutputTail = 8 * 1024
+)
+
+// commandResult is the result of the command on a host.
+type commandResult struct {
+	*ssh.ExecResult
+	Duration string `json:"duration"`
+	Shell    string `json:"shell"`
+
+	// Truncated is true when only the head and tail of the output are in the result, the full output
+	// is stored to be read with command_output by the OutputID.
+	Truncated   bool   `json:"truncated,omitempty"`
+	StdoutSize  int64  `json:"stdout_size,omitempty"`
+	StderrSize  int64  `json:"stderr_size,omitempty"`
+	OutputID    string `json:"output_id,omitempty"`
+	OutputError string `json:"output_error,omitempty"`
+}
+
+// newCommandResult creates the command result from the result of the execution on the host.
+func newCommandResult(host ssh.ClientInfo, result *ssh.ExecResult) commandResult {
+	return commandResult{
+		ExecResult: result,
+		Duration:   result.Duration.Round(time.Millisecond).String(),
+		Shell:      host.Shell(),
+	}
+}
+
+// setOutput sets the output of the command from the spill it was written to, only the head and tail
+// bytes of each stream are in the result when the output was larger and stored in the storage directory.
+//
+// Failing to store the full output does not fail the command, that is reported in the result instead.
+func (r *commandResult) setOutput(spill *outputSpill) {
+	err := spill.close()
+	r.Stdout = spill.stdout.String()
+	r.Stderr = spill.stderr.String()
+	if !spill.spilled() {
+		return
+	}
+	r.Truncated = true
+	r.StdoutSize = spill.stdout.Size()
+	r.StderrSize = spill.stderr.Size()
+	if err != nil {
+		r.OutputError = fmt.Sprintf("failed to store the full output: %s", err)
+	} else {
+		r.OutputID = spill.output.ID
+	}
+}
+
 // PerformCommand is a tool that executes a command on a remote machine.
 type PerformCommand struct{}
 
 // Definition returns the mcp.Tool definition.
 func (c *PerformCommand) Definition() mcp.Tool {
-	return mcp.NewTool("perform_command",
-		mcp.WithDescription("SSH into a remote machine and executes a command."),
+	return mcp.NewTool("perform_command", append([]mcp.ToolOption{
+		mcp.WithDescription("SSH into a remote machine and executes a command. " +
+			"Reports the stdout, stderr, exit code, terminating signal and duration of the command on each host. " +
+			"The output is streamed as log messages (and progress notifications when requested) tagged with the host while the command runs. " +
+			"The command runs in the shell of the host (the shell in the OS information): sh on Linux, cmd or powershell on Windows. " +
+			"Large output is truncated to its head and tail, the full output is read with command_output by the output_id of the result."),
 		mcp.WithArray("name_of_hosts",
 			mcp.Required(),
 			mcp.Description("Name of the hosts"),
 			mcp.WithStringItems(),
 		),
 		mcp.WithString("command", mcp.Required(), mcp.Description("The command to execute")),
-	)
+		mcp.WithString("timeout",
+			mcp.Description("How long the command can run before it is cancelled (e.g. 30s or 5m), the command timeout of the hosts still applies when it is shorter"),
+		),
+		mcp.WithString("connect_timeout",
+			mcp.Description("How long to wait for the connection to each host (e.g. 10s), the connect timeout of the hosts still applies when it is shorter"),
+		),
+		mcp.WithNumber("output_head_bytes",
+			mcp.Description("Bytes kept from the start of stdout and of stderr when the output is truncated (defaults to 8KiB)"),
+		),
+		mcp.WithNumber("output_tail_bytes",
+			mcp.Description("Bytes kept from the end of stdout and of stderr when the output is truncated (defaults to 8KiB)"),
+		),
+	}, commandToolOptions()...)...)
 }
 
 // Handle is the function that is called when the tool is invoked.
-func (c *PerformCommand) Handler(storageEngine *storage.Engine, aiClient openai.Client) server.ToolHandlerFunc {
+func (c *PerformCommand) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		sshNameOfHosts, err := request.RequireStringSlice("name_of_hosts")
 		if err != nil {
@@ -48,6 +118,26 @@ func (c *PerformCommand) Handler(storageEngine *storage.Engine, aiClient openai.
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
+		timeout, err := ssh.ParseTimeout(request.GetString("timeout", ""))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		connectTimeout, err := ssh.ParseTimeout(request.GetString("connect_timeout", ""))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		head := request.GetInt("output_head_bytes", defaultOutputHead)
+		tail := request.GetInt("output_tail_bytes", defaultOutputTail)
+		if head < 0 || tail < 0 {
+			return mcp.NewToolResultError("output_head_bytes and output_tail_bytes must not be negative"), nil
+		}
+
+		execOpts, err := commandExecOptions(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
 		found, err := getHostsFromStorage(storageEngine, sshNameOfHosts)
 		if err != nil {
 			return mcp.NewToolResultError(err.Error()), nil
@@ -56,15 +146,104 @@ func (c *PerformCommand) Handler(storageEngine *storage.Engine, aiClient openai.
 			return mcp.NewToolResultError("no matching hosts found"), nil
 		}
 
-		result := performTasksOnHosts(found, func(_ ssh.ClientInfo, sshClient *ssh.Client) (string, error) {
-			// sudo is required to update and upgrade
-			output, err := sshClient.Exec(commandStr)
+		notifier := newOutputNotifier(ctx, request)
+		result := performTasksOnHosts(ctx, storageEngine, manager, found, connectTimeout, func(host ssh.ClientInfo, sshClient *ssh.Client) (any, error) {
+			execCtx := ctx
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				execCtx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			// the output is streamed to storage once it is too large, so only its head and tail are held in memory
+			spill := newOutputSpill(storageEngine, head, tail)
+			output, err := sshClient.Exec(execCtx, commandStr, append(slices.Clone(execOpts), ssh.WithOutputLines(notifier.Host(host.Name)), spill.option())...)
 			if err != nil {
-				return "", fmt.Errorf("failed to execute command: %w", err)
+				spill.discard()
+				return nil, fmt.Errorf("failed to execute command: %w", err)
 			}
-			return string(output), nil
+			result := newCommandResult(host, output)
+			result.setOutput(spill)
+			return result, nil
 		})
 
 		return mcp.NewToolResultStructuredOnly(result), nil
 	}
 }
+
+// commandToolOptions are the parameters of the tools that run commands for how the command is run.
+func commandToolOptions() []mcp.ToolOption {
+	return []mcp.ToolOption{
+		mcp.WithString("stdin",
+			mcp.Description("Data to write to the standard input of the command (e.g. the content for sudo tee or a psql script)"),
+		),
+		mcp.WithString("stdin_encoding",
+			mcp.Description("Encoding of the stdin data"),
+			mcp.Enum("text", "base64"),
+			mcp.DefaultString("text"),
+		),
+		mcp.WithObject("env",
+			mcp.Description("Environment variables of the command (on top of the environment of the hosts)"),
+			mcp.AdditionalProperties(map[string]any{"type": "string"}),
+		),
+		mcp.WithString("cwd",
+			mcp.Description("Working directory of the command"),
+		),
+		mcp.WithBoolean("become",
+			mcp.Description("Run the command with escalated privileges using the become method of the hosts (sudo by default), "+
+				"the password prompt is answered with the stored become password instead of prefixing the command with sudo"),
+		),
+		mcp.WithString("become_user",
+			mcp.Description("User to become (defaults to root), implies become"),
+		),
+		mcp.WithBoolean("pty",
+			mcp.Description("Run the command in a pseudo-terminal, for commands that require a TTY (stdout and stderr are combined and ANSI escape sequences are removed)"),
+		),
+		mcp.WithString("pty_term",
+			mcp.Description("TERM of the pseudo-terminal (defaults to xterm)"),
+		),
+		mcp.WithNumber("pty_width",
+			mcp.Description("Number of columns of the pseudo-terminal (defaults to 80)"),
+		),
+		mcp.WithNumber("pty_height",
+			mcp.Description("Number of rows of the pseudo-terminal (defaults to 24)"),
+		),
+	}
+}
+
+// commandExecOptions returns the options for running the command from the parameters of commandToolOptions.
+func commandExecOptions(request mcp.CallToolRequest) ([]ssh.ExecOption, error) {
+	var execOpts []ssh.ExecOption
+	if stdin := request.GetString("stdin", ""); stdin != "" {
+		data, err := decodeContent(stdin, request.GetString("stdin_encoding", "text"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid stdin: %w", err)
+		}
+		execOpts = append(execOpts, ssh.WithStdin(data))
+	}
+	env, err := getStringMap(request.GetArguments(), "env")
+	if err != nil {
+		return nil, err
+	}
+	err = ssh.ValidateEnv(env)
+	if err != nil {
+		return nil, err
+	}
+	if len(env) > 0 {
+		execOpts = append(execOpts, ssh.WithEnv(env))
+	}
+	if cwd := request.GetString("cwd", ""); cwd != "" {
+		execOpts = append(execOpts, ssh.WithDir(cwd))
+	}
+	if becomeUser := request.GetString("become_user", ""); becomeUser != "" || request.GetBool("become", false) {
+		execOpts = append(execOpts, ssh.WithBecome(becomeUser))
+	}
+	if request.GetBool("pty", false) {
+		execOpts = append(execOpts, ssh.WithPTY(
+			request.GetString("pty_term", ""),
+			request.GetInt("pty_width", 0),
+			request.GetInt("pty_height", 0),
+		))
+	}
+	return execOpts, nil
+}
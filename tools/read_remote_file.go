@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&ReadRemoteFile{})
+}
+
+// defaultReadLimit is the number of bytes read when no limit is provided.
+const defaultReadLimit = 64 * 1024
+
+// fileRead is the result of reading a remote file.
+type fileRead struct {
+	*ssh.FileContent
+	Bytes    int    `json:"bytes"`
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+}
+
+// ReadRemoteFile is a tool that reads a file on remote machines.
+type ReadRemoteFile struct{}
+
+// Definition returns the mcp.Tool definition.
+func (c *ReadRemoteFile) Definition() mcp.Tool {
+	return mcp.NewTool("read_remote_file",
+		mcp.WithDescription("Reads a file on remote machines over SFTP. "+
+			"Large files are read in parts using offset and limit, truncated is true when the file continues after the returned content. "+
+			"Content that is not valid UTF-8 is returned base64 encoded."),
+		mcp.WithArray("name_of_hosts",
+			mcp.Required(),
+			mcp.Description("Name of the hosts"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("Path of the file on the hosts"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Byte offset to start reading from (defaults to 0)"),
+			mcp.Min(0),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of bytes to read (defaults to %d, at most %d)", defaultReadLimit, maxFileSize)),
+			mcp.Min(1),
+			mcp.Max(maxFileSize),
+		),
+		mcp.WithString("encoding",
+			mcp.Description("Encoding of the returned content"),
+			mcp.Enum("text", "base64"),
+			mcp.DefaultString("text"),
+		),
+	)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *ReadRemoteFile) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sshNameOfHosts, err := request.RequireStringSlice("name_of_hosts")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(sshNameOfHosts) == 0 {
+			return mcp.NewToolResultError("no hosts provided"), nil
+		}
+		path, err := request.RequireString("path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		offset := request.GetInt("offset", 0)
+		if offset < 0 {
+			return mcp.NewToolResultError("offset must not be negative"), nil
+		}
+		limit := request.GetInt("limit", defaultReadLimit)
+		if limit <= 0 || limit > maxFileSize {
+			return mcp.NewToolResultError(fmt.Sprintf("limit must be between 1 and %d", maxFileSize)), nil
+		}
+		encoding := request.GetString("encoding", "text")
+		if encoding != "text" && encoding != "base64" {
+			return mcp.NewToolResultError(fmt.Sprintf("unknown encoding %q", encoding)), nil
+		}
+
+		found, err := getHostsFromStorage(storageEngine, sshNameOfHosts)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(found) == 0 {
+			return mcp.NewToolResultError("no matching hosts found"), nil
+		}
+
+		result := performTasksOnHosts(ctx, storageEngine, manager, found, 0, func(_ ssh.ClientInfo, sshClient *ssh.Client) (any, error) {
+			content, err := sshClient.ReadFile(ctx, path, int64(offset), int64(limit))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read file: %w", err)
+			}
+			read := fileRead{
+				FileContent: content,
+				Bytes:       len(content.Data),
+				Encoding:    encoding,
+			}
+			// a part of the file can split a multi-byte character, so binary data is always encoded
+			if encoding == "text" && utf8.Valid(content.Data) {
+				read.Content = string(content.Data)
+			} else {
+				read.Encoding = "base64"
+				read.Content = base64.StdEncoding.EncodeToString(content.Data)
+			}
+			return read, nil
+		})
+
+		return mcp.NewToolResultStructuredOnly(result), nil
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/openai/openai-go/v2"
 
+	"github.com/blakerouse/sshai/ssh"
 	"github.com/blakerouse/sshai/storage"
 )
 
@@ -31,7 +32,7 @@ func (c *RemoveHost) Definition() mcp.Tool {
 }
 
 // Handle is the function that is called when the tool is invoked.
-func (c *RemoveHost) Handler(storageEngine *storage.Engine, aiClient openai.Client) server.ToolHandlerFunc {
+func (c *RemoveHost) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		sshNameOfHost, err := request.RequireString("name_of_host")
 		if err != nil {
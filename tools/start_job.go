@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&StartJob{})
+}
+
+// StartJob is a tool that starts a command in the background on remote machines.
+type StartJob struct{}
+
+// Definition returns the mcp.Tool definition.
+func (c *StartJob) Definition() mcp.Tool {
+	return mcp.NewTool("start_job", append([]mcp.ToolOption{
+		mcp.WithDescription("Starts a long running command (e.g. a database migration) in the background on each host and returns the job IDs right away. " +
+			"Use job_status to see if it finished, job_output to read its output and kill_job to signal it. " +
+			"The command runs in the shell of the host (the shell in the OS information) and the command timeout of the host does not apply."),
+		mcp.WithArray("name_of_hosts",
+			mcp.Required(),
+			mcp.Description("Name of the hosts"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("command", mcp.Required(), mcp.Description("The command to execute")),
+		mcp.WithString("connect_timeout",
+			mcp.Description("How long to wait for the connection to each host (e.g. 10s), the connect timeout of the hosts still applies when it is shorter"),
+		),
+		mcp.WithNumber("output_size",
+			mcp.Description("Number of bytes of the most recent output that is kept for each job (defaults to 1MiB, at most 64MiB)"),
+		),
+	}, commandToolOptions()...)...)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *StartJob) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sshNameOfHosts, err := request.RequireStringSlice("name_of_hosts")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(sshNameOfHosts) == 0 {
+			return mcp.NewToolResultError("no hosts provided"), nil
+		}
+		commandStr, err := request.RequireString("command")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		connectTimeout, err := ssh.ParseTimeout(request.GetString("connect_timeout", ""))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		outputSize := request.GetInt("output_size", 0)
+		err = ssh.ValidateJobOutputSize(outputSize)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		execOpts, err := commandExecOptions(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		found, err := getHostsFromStorage(storageEngine, sshNameOfHosts)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		result := performTasksOnHosts(ctx, storageEngine, manager, found, connectTimeout, func(host ssh.ClientInfo, _ *ssh.Client) (any, error) {
+			// the job holds its own reference to the connection as it outlives the tool call
+			jobClient, release, err := getClient(ctx, storageEngine, manager, host)
+			if err != nil {
+				return nil, err
+			}
+			job, err := manager.StartJob(jobClient, release, commandStr, outputSize, execOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to start job: %w", err)
+			}
+			return job, nil
+		})
+
+		return mcp.NewToolResultStructuredOnly(result), nil
+	}
+}
@@ -33,7 +33,7 @@ func (c *UpdateOSInfo) Definition() mcp.Tool {
 }
 
 // Handle is the function that is called when the tool is invoked.
-func (c *UpdateOSInfo) Handler(storageEngine *storage.Engine, aiClient openai.Client) server.ToolHandlerFunc {
+func (c *UpdateOSInfo) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		sshNameOfHosts, err := request.RequireStringSlice("name_of_hosts")
 		if err != nil {
@@ -51,30 +51,18 @@ func (c *UpdateOSInfo) Handler(storageEngine *storage.Engine, aiClient openai.Cl
 			return mcp.NewToolResultError("no matching hosts found"), nil
 		}
 
-		// from this point forward it is very much assuming linux
-		// this really should be improved to do more checks to see if this macOS or Windows
-
-		result := performTasksOnHosts(found, func(host ssh.ClientInfo, sshClient *ssh.Client) (string, error) {
-			osRelease, err := sshClient.Exec("cat /etc/os-release")
-			if err != nil {
-				return "", fmt.Errorf("failed to get output of /etc/os-release: %w", err)
-			}
-			uname, err := sshClient.Exec("uname -a")
-			if err != nil {
-				return "", fmt.Errorf("failed to get output of uname -a: %w", err)
-			}
-
-			// send the output to OpenAI to get a summary of what needs to be updated
-			osInfo, err := getOSInfo(ctx, aiClient, string(osRelease), string(uname))
+		result := performTasksOnHosts(ctx, storageEngine, manager, found, 0, func(host ssh.ClientInfo, sshClient *ssh.Client) (any, error) {
+			osInfo, err := detectOSInfo(ctx, aiClient, sshClient)
 			if err != nil {
-				return "", fmt.Errorf("failed to summarize OS information: %w", err)
+				return nil, err
 			}
 
 			// set the OS info and store it for usage later
 			host.OS = *osInfo
+			host.Algorithms = sshClient.Algorithms()
 			err = storageEngine.Set(host)
 			if err != nil {
-				return "", fmt.Errorf("failed to add host to storage: %w", err)
+				return nil, fmt.Errorf("failed to add host to storage: %w", err)
 			}
 			return fmt.Sprintf("successfully updated %s", host.Name), nil
 		})
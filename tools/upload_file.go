@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&UploadFile{})
+}
+
+// UploadFile is a tool that uploads a local file to remote machines.
+type UploadFile struct{}
+
+// Definition returns the mcp.Tool definition.
+func (c *UploadFile) Definition() mcp.Tool {
+	return mcp.NewTool("upload_file",
+		mcp.WithDescription("Uploads a local file to remote machines over SFTP, preserving its mode bits. "+
+			"Reports the bytes written and the SHA256 checksum on each host."),
+		mcp.WithArray("name_of_hosts",
+			mcp.Required(),
+			mcp.Description("Name of the hosts"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("local_path",
+			mcp.Required(),
+			mcp.Description("Path of the local file to upload"),
+		),
+		mcp.WithString("remote_path",
+			mcp.Required(),
+			mcp.Description("Path to upload the file to on the hosts (an existing directory keeps the name of the file)"),
+		),
+	)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *UploadFile) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sshNameOfHosts, err := request.RequireStringSlice("name_of_hosts")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(sshNameOfHosts) == 0 {
+			return mcp.NewToolResultError("no hosts provided"), nil
+		}
+		localPath, err := request.RequireString("local_path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		remotePath, err := request.RequireString("remote_path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		found, err := getHostsFromStorage(storageEngine, sshNameOfHosts)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(found) == 0 {
+			return mcp.NewToolResultError("no matching hosts found"), nil
+		}
+
+		result := performTasksOnHosts(ctx, storageEngine, manager, found, 0, func(_ ssh.ClientInfo, sshClient *ssh.Client) (any, error) {
+			transfer, err := sshClient.Upload(ctx, localPath, remotePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to upload file: %w", err)
+			}
+			return transfer, nil
+		})
+
+		return mcp.NewToolResultStructuredOnly(result), nil
+	}
+}
@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go/v2"
+
+	"github.com/blakerouse/sshai/ssh"
+	"github.com/blakerouse/sshai/storage"
+)
+
+func init() {
+	// register the tool in the registry
+	Registry.Register(&WriteRemoteFile{})
+}
+
+// WriteRemoteFile is a tool that writes a file on remote machines.
+type WriteRemoteFile struct{}
+
+// Definition returns the mcp.Tool definition.
+func (c *WriteRemoteFile) Definition() mcp.Tool {
+	return mcp.NewTool("write_remote_file",
+		mcp.WithDescription("Writes a file on remote machines over SFTP. "+
+			"The file is replaced atomically (written to a temporary file that is renamed over it) and keeps its mode bits. "+
+			"Returns a unified diff of what changed on each host, the diff is omitted when the replaced file is larger than 1MiB."),
+		mcp.WithArray("name_of_hosts",
+			mcp.Required(),
+			mcp.Description("Name of the hosts"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("Path of the file on the hosts"),
+		),
+		mcp.WithString("content",
+			mcp.Required(),
+			mcp.Description("The new content of the file"),
+		),
+		mcp.WithString("content_encoding",
+			mcp.Description("Encoding of the content"),
+			mcp.Enum("text", "base64"),
+			mcp.DefaultString("text"),
+		),
+		mcp.WithBoolean("backup",
+			mcp.Description("Copy the existing file to a timestamped .bak file next to it first"),
+		),
+	)
+}
+
+// Handle is the function that is called when the tool is invoked.
+func (c *WriteRemoteFile) Handler(storageEngine *storage.Engine, manager *ssh.Manager, aiClient openai.Client) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sshNameOfHosts, err := request.RequireStringSlice("name_of_hosts")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(sshNameOfHosts) == 0 {
+			return mcp.NewToolResultError("no hosts provided"), nil
+		}
+		path, err := request.RequireString("path")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		content, err := request.RequireString("content")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		data, err := decodeContent(content, request.GetString("content_encoding", "text"))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(data) > maxFileSize {
+			return mcp.NewToolResultError(fmt.Sprintf("content is larger than %d bytes, use upload_file instead", maxFileSize)), nil
+		}
+		backup := request.GetBool("backup", false)
+
+		found, err := getHostsFromStorage(storageEngine, sshNameOfHosts)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if len(found) == 0 {
+			return mcp.NewToolResultError("no matching hosts found"), nil
+		}
+
+		result := performTasksOnHosts(ctx, storageEngine, manager, found, 0, func(_ ssh.ClientInfo, sshClient *ssh.Client) (any, error) {
+			existing, err := sshClient.ReadFile(ctx, path, 0, maxFileSize)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("failed to read file: %w", err)
+			}
+			written, err := sshClient.WriteFile(ctx, path, data, backup)
+			if err != nil {
+				return nil, fmt.Errorf("failed to write file: %w", err)
+			}
+			var before string
+			if existing != nil {
+				// the file is replaced as a whole, so a file too large to diff does not stop the write
+				if existing.Truncated {
+					return fileChange{
+						WriteResult: written,
+						Diff:        fmt.Sprintf("File %s replaced (%d bytes, diff omitted)", written.Path, existing.Size),
+					}, nil
+				}
+				before = string(existing.Data)
+			}
+			return newFileChange(written, before, string(data)), nil
+		})
+
+		return mcp.NewToolResultStructuredOnly(result), nil
+	}
+}
@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// UnifiedDiff returns the unified diff of the change to the file at path (empty when nothing changed).
+func UnifiedDiff(path string, before string, after string) string {
+	if before == after {
+		return ""
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(before),
+		B:        splitLines(after),
+		FromFile: "a" + ensureLeadingSlash(path),
+		ToFile:   "b" + ensureLeadingSlash(path),
+		Context:  3,
+	})
+	if err != nil {
+		// only fails when writing to the buffer fails
+		return ""
+	}
+	return diff
+}
+
+// splitLines splits the content into lines that all end with a newline.
+//
+// A last line without a newline is followed by the marker patch uses for it.
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	last := len(lines) - 1
+	if lines[last] == "" {
+		return lines[:last]
+	}
+	lines[last] += "\n\\ No newline at end of file\n"
+	return lines
+}
+
+func ensureLeadingSlash(path string) string {
+	if strings.HasPrefix(path, "/") {
+		return path
+	}
+	return "/" + path
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+type hunk struct {
+	oldStart int
+	oldLines []string
+	newLines []string
+}
+
+// ApplyPatch applies the unified diff patch of a single file to the content.
+//
+// Each hunk must match the content exactly, but like patch it is found even when the lines moved.
+func ApplyPatch(content string, patch string) (string, error) {
+	hunks, noNewline, err := parsePatch(patch)
+	if err != nil {
+		return "", err
+	}
+	if len(hunks) == 0 {
+		return "", errors.New("patch contains no hunks")
+	}
+
+	trailingNewline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	offset := 0
+	for i, h := range hunks {
+		expected := h.oldStart - 1 + offset
+		if len(h.oldLines) == 0 {
+			// pure addition, the start is the line after which the lines are added
+			expected = h.oldStart + offset
+		}
+		expected = max(0, min(expected, len(lines)))
+		pos := findHunk(lines, h.oldLines, expected)
+		if pos < 0 {
+			return "", fmt.Errorf("hunk %d (line %d) does not match the content", i+1, h.oldStart)
+		}
+		updated := make([]string, 0, len(lines)-len(h.oldLines)+len(h.newLines))
+		updated = append(updated, lines[:pos]...)
+		updated = append(updated, h.newLines...)
+		updated = append(updated, lines[pos+len(h.oldLines):]...)
+		lines = updated
+		offset += pos - expected + len(h.newLines) - len(h.oldLines)
+	}
+
+	if noNewline != nil {
+		trailingNewline = !*noNewline
+	}
+	result := strings.Join(lines, "\n")
+	if trailingNewline && len(lines) > 0 {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// parsePatch parses the hunks of the patch.
+//
+// Also returns whether the patched file ends without a newline when the patch says so.
+//
+// The "---" and "+++" file headers are only read outside of a hunk or once all of its lines were
+// read, inside a hunk they are lines that are removed or added (e.g. "++ counter" that is added).
+func parsePatch(patch string) ([]hunk, *bool, error) {
+	var hunks []hunk
+	var noNewline *bool
+	files := 0
+	var current *hunk
+	oldLeft, newLeft := 0, 0
+	lastOp := byte(0)
+	for _, line := range strings.Split(strings.TrimSuffix(patch, "\n"), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		header := current == nil || (oldLeft <= 0 && newLeft <= 0)
+		switch {
+		case strings.HasPrefix(line, "+++ ") && header:
+			files++
+			if files > 1 {
+				return nil, nil, errors.New("patch changes more than one file")
+			}
+			current = nil
+			continue
+		case strings.HasPrefix(line, "--- ") && header:
+			continue
+		case strings.HasPrefix(line, "@@"):
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, nil, fmt.Errorf("invalid hunk header %q", line)
+			}
+			start, _ := strconv.Atoi(m[1])
+			oldLeft, newLeft = hunkLineCount(m[2]), hunkLineCount(m[4])
+			hunks = append(hunks, hunk{oldStart: start})
+			current = &hunks[len(hunks)-1]
+			continue
+		}
+		if current == nil {
+			// headers such as "diff --git" or "index" before the first hunk
+			continue
+		}
+		if line == "" {
+			// some tools strip the space of empty context lines
+			line = " "
+		}
+		switch line[0] {
+		case ' ':
+			current.oldLines = append(current.oldLines, line[1:])
+			current.newLines = append(current.newLines, line[1:])
+			oldLeft--
+			newLeft--
+		case '-':
+			current.oldLines = append(current.oldLines, line[1:])
+			oldLeft--
+		case '+':
+			current.newLines = append(current.newLines, line[1:])
+			newLeft--
+		case '\\':
+			// "\ No newline at end of file" applies to the line before it
+			if lastOp != '-' {
+				v := true
+				noNewline = &v
+			} else if noNewline == nil {
+				v := false
+				noNewline = &v
+			}
+			continue
+		default:
+			return nil, nil, fmt.Errorf("invalid patch line %q", line)
+		}
+		lastOp = line[0]
+	}
+	return hunks, noNewline, nil
+}
+
+// hunkLineCount returns the number of lines of a hunk header, which is 1 when it is omitted.
+func hunkLineCount(count string) int {
+	if count == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(count)
+	return n
+}
+
+// findHunk returns the position of the lines in the content closest to the expected position (-1 when not found).
+func findHunk(content []string, lines []string, expected int) int {
+	matches := func(pos int) bool {
+		if pos < 0 || pos+len(lines) > len(content) {
+			return false
+		}
+		for i, line := range lines {
+			if content[pos+i] != line {
+				return false
+			}
+		}
+		return true
+	}
+	for distance := 0; distance <= len(content); distance++ {
+		if matches(expected - distance) {
+			return expected - distance
+		}
+		if matches(expected + distance) {
+			return expected + distance
+		}
+	}
+	return -1
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+const testConfig = `user www-data;
+worker_processes auto;
+
+events {
+    worker_connections 768;
+}
+
+http {
+    sendfile on;
+    keepalive_timeout 65;
+}
+`
+
+func TestUnifiedDiff(t *testing.T) {
+	if diff := UnifiedDiff("/etc/nginx.conf", testConfig, testConfig); diff != "" {
+		t.Errorf("expected no diff for unchanged content, got %q", diff)
+	}
+
+	after := strings.Replace(testConfig, "768", "1024", 1)
+	diff := UnifiedDiff("/etc/nginx.conf", testConfig, after)
+	for _, expected := range []string{"--- a/etc/nginx.conf", "+++ b/etc/nginx.conf", "-    worker_connections 768;", "+    worker_connections 1024;"} {
+		if !strings.Contains(diff, expected) {
+			t.Errorf("expected diff to contain %q, got:\n%s", expected, diff)
+		}
+	}
+}
+
+func TestApplyPatch_RoundTrip(t *testing.T) {
+	after := strings.Replace(testConfig, "auto", "4", 1)
+	after = strings.Replace(after, "    keepalive_timeout 65;\n", "    keepalive_timeout 30;\n    gzip on;\n", 1)
+
+	patched, err := ApplyPatch(testConfig, UnifiedDiff("/etc/nginx.conf", testConfig, after))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if patched != after {
+		t.Errorf("expected patched content:\n%s\ngot:\n%s", after, patched)
+	}
+}
+
+func TestApplyPatch_MovedLines(t *testing.T) {
+	patch := `--- a/etc/nginx.conf
++++ b/etc/nginx.conf
+@@ -1,3 +1,3 @@
+ http {
+-    sendfile on;
++    sendfile off;
+     keepalive_timeout 65;
+`
+	patched, err := ApplyPatch(testConfig, patch)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.Contains(patched, "    sendfile off;\n") || strings.Contains(patched, "sendfile on") {
+		t.Errorf("expected hunk to be applied where it matches, got:\n%s", patched)
+	}
+}
+
+func TestApplyPatch_NoNewline(t *testing.T) {
+	patch := `@@ -1 +1 @@
+-a
+\ No newline at end of file
++b
+`
+	patched, err := ApplyPatch("a", patch)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if patched != "b\n" {
+		t.Errorf("expected b with a newline, got %q", patched)
+	}
+}
+
+func TestApplyPatch_LinesLikeHeaders(t *testing.T) {
+	// the added "++ " and removed "-- " lines are not file headers inside the hunk
+	patch := `--- a/main.c
++++ b/main.c
+@@ -1,3 +1,3 @@
+ int main() {
+--- i;
++++ i;
+ }
+`
+	patched, err := ApplyPatch("int main() {\n-- i;\n}\n", patch)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if patched != "int main() {\n++ i;\n}\n" {
+		t.Errorf("expected the line to be replaced, got %q", patched)
+	}
+}
+
+func TestApplyPatch_Mismatch(t *testing.T) {
+	patch := `@@ -1,1 +1,1 @@
+-missing line
++replacement
+`
+	_, err := ApplyPatch(testConfig, patch)
+	if err == nil {
+		t.Fatalf("expected error for a hunk that does not match")
+	}
+}
+
+func TestApplyPatch_RoundTripNoNewline(t *testing.T) {
+	before := "a\nb"
+	after := "a\nc\n"
+	patched, err := ApplyPatch(before, UnifiedDiff("file", before, after))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if patched != after {
+		t.Errorf("expected %q, got %q", after, patched)
+	}
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// JoinTruncated joins the head and the tail of an output that were kept, with a marker of how many
+// bytes were removed between them.
+//
+// A UTF-8 character that is split at the end of the head or the start of the tail is removed as well.
+func JoinTruncated(head string, tail string, removed int64) string {
+	last := len(head) - 1
+	for last > 0 && !utf8.RuneStart(head[last]) {
+		last--
+	}
+	if last >= 0 && !utf8.FullRuneInString(head[last:]) {
+		removed += int64(len(head) - last)
+		head = head[:last]
+	}
+	start := 0
+	for start < len(tail) && !utf8.RuneStart(tail[start]) {
+		start++
+	}
+	removed += int64(start)
+	return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", head, removed, tail[start:])
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestJoinTruncated(t *testing.T) {
+	output := JoinTruncated("line 1\n", "line 3\n", 7)
+	if output != "line 1\n\n... [7 bytes truncated] ...\nline 3\n" {
+		t.Errorf("expected the head and tail, got %q", output)
+	}
+
+	// only the tail
+	output = JoinTruncated("", "end", 10)
+	if output != "\n... [10 bytes truncated] ...\nend" {
+		t.Errorf("expected only the tail, got %q", output)
+	}
+
+	// the halves of the 2 byte é that were cut are removed as well
+	output = JoinTruncated("a\xc3", "\xa9a", 12)
+	if output != "a\n... [14 bytes truncated] ...\na" {
+		t.Errorf("expected the cuts on character boundaries, got %q", output)
+	}
+}